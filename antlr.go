@@ -0,0 +1,918 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ANTLR4 grammars.
+// readantlr reads the parser rules of an ANTLR4 .g4 grammar from stdin
+// into the global grammar structure.  The first parser rule is taken as
+// the distinguished symbol.  Lexer rules are skipped, so the tokens they
+// define are terminals, as are 'literal' tokens, which keep their quotes.
+// Subrules are kept as EBNF, so
+//    |
+//    |expr : term (('+' | '-') term)* ;
+//    |
+// reads as
+//    |
+//    |expr ::= term { ( '+' | '-' ) term }
+//    |
+// with x? becoming [ x ] and x+ becoming x { x }, and a range 'a'..'c'
+// becoming ( 'a' | 'b' | 'c' ), up to MAXRANGE terminals as in Go EBNF.
+// Actions, predicates, labels, arguments, options and lexer modes are
+// ignored.  An alternative holding a not set ~x or the wildcard, which
+// EBNF can't say, is reported and left out whole, since leaving out just
+// that element would change what it matches.  If any rule needs an empty
+// alternative, '' is used as the empty symbol.
+//
+// writeantlr writes the global grammar structure as an ANTLR4 combined
+// grammar.  Quoted terminals become literal tokens and other terminals
+// become token references; those that are punctuation get a lexer rule
+// of their own, and the rest are declared in a tokens { } block, so that
+// the grammar loads.  Rules that can't be reached from the distinguished
+// symbol follow the others, after a comment, as writeg puts them.
+
+func ReadANTLR() {
+	readantlr()
+}
+
+func WriteANTLR(name string) {
+	writeantlr(name)
+}
+
+// ANTLR lexical analysis
+
+// kinds of ANTLR token, punctuation is its own kind
+const (
+	ATEOF    = iota + 256 // end of file
+	ATID                  // identifier
+	ATSTRING              // 'literal'
+	ATACTION              // { action }
+	ATARGS                // [ arguments ]
+	ATOPTS                // < element options >
+	ATPLUSEQ              // +=
+	ATARROW               // ->
+	ATRANGE               // ..
+	ATCOLONS              // ::
+)
+
+type atoken struct {
+	kind int    // kind of token
+	text string // text of token
+	line int    // source line number on which token starts
+	pos  span   // where token is
+}
+
+// global variables private to this package
+var _antlr struct {
+	src  []byte   // the grammar being read
+	pos  int      // position of next unread character in src
+	line int      // current line number in src
+	toks []atoken // tokens read from src
+	next int      // index of next unparsed token in toks
+}
+
+// get the character at offset i from the current position, or 0
+func apeek(i int) byte {
+	if _antlr.pos+i < len(_antlr.src) {
+		return _antlr.src[_antlr.pos+i]
+	}
+	return 0
+}
+
+// advance over one character, counting lines
+func askip() {
+	if apeek(0) == '\n' {
+		_antlr.line++
+	}
+	_antlr.pos++
+}
+
+// skip a balanced [ ] or { } group, including any quoted strings in it
+func askipgroup(open, close byte) {
+	var nest int
+
+	for _antlr.pos < len(_antlr.src) {
+		c := apeek(0)
+		if c == '\'' || c == '"' {
+			askipquoted(c)
+			continue
+		}
+		askip()
+		if c == '\\' {
+			askip()
+		} else if c == open {
+			nest++
+		} else if c == close {
+			nest--
+			if nest == 0 {
+				return
+			}
+		}
+	}
+	errormsg("UNBALANCED ANTLR BRACKETS", _antlr.line)
+}
+
+// skip a quoted string, allowing for backslash escapes
+func askipquoted(q byte) {
+	askip()
+	for _antlr.pos < len(_antlr.src) && apeek(0) != q && apeek(0) != '\n' {
+		if apeek(0) == '\\' {
+			askip()
+		}
+		askip()
+	}
+	if apeek(0) == q {
+		askip()
+	} else {
+		errormsg("MISSING CLOSING QUOTE", _antlr.line)
+	}
+}
+
+func isidentchar(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || c >= 0x80
+}
+
+// break the grammar source up into tokens
+func alex() {
+	var tok atoken
+	var start int
+
+	for {
+		// skip white space and comments
+		for _antlr.pos < len(_antlr.src) {
+			c := apeek(0)
+			if c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' {
+				askip()
+			} else if c == '/' && apeek(1) == '/' {
+				for _antlr.pos < len(_antlr.src) && apeek(0) != '\n' {
+					askip()
+				}
+			} else if c == '/' && apeek(1) == '*' {
+				askip()
+				askip()
+				for _antlr.pos < len(_antlr.src) && !(apeek(0) == '*' && apeek(1) == '/') {
+					askip()
+				}
+				askip()
+				askip()
+			} else {
+				break
+			}
+		}
+
+		tok.line = _antlr.line
+		start = _antlr.pos
+		if _antlr.pos >= len(_antlr.src) {
+			tok.kind = ATEOF
+			tok.text = ""
+			_antlr.toks = append(_antlr.toks, tok)
+			return
+		}
+
+		c := apeek(0)
+		switch {
+		case isidentchar(c):
+			for _antlr.pos < len(_antlr.src) && isidentchar(apeek(0)) {
+				askip()
+			}
+			tok.kind = ATID
+		case c == '\'':
+			askipquoted(c)
+			tok.kind = ATSTRING
+		case c == '{':
+			askipgroup('{', '}')
+			tok.kind = ATACTION
+		case c == '[':
+			askipgroup('[', ']')
+			tok.kind = ATARGS
+		case c == '<':
+			for _antlr.pos < len(_antlr.src) && apeek(0) != '>' {
+				askip()
+			}
+			askip()
+			tok.kind = ATOPTS
+		case c == '+' && apeek(1) == '=':
+			askip()
+			askip()
+			tok.kind = ATPLUSEQ
+		case c == '-' && apeek(1) == '>':
+			askip()
+			askip()
+			tok.kind = ATARROW
+		case c == '.' && apeek(1) == '.':
+			askip()
+			askip()
+			tok.kind = ATRANGE
+		case c == ':' && apeek(1) == ':':
+			askip()
+			askip()
+			tok.kind = ATCOLONS
+		default:
+			askip()
+			tok.kind = int(c)
+		}
+		tok.text = string(_antlr.src[start:_antlr.pos])
+		tok.pos = bytespan(_antlr.src, tok.line, start, _antlr.pos)
+		_antlr.toks = append(_antlr.toks, tok)
+	}
+}
+
+// ANTLR parsing
+
+// the current token
+func atok() *atoken {
+	return &_antlr.toks[_antlr.next]
+}
+
+// the token i places after the current token
+func alook(i int) *atoken {
+	if _antlr.next+i >= len(_antlr.toks) {
+		return &_antlr.toks[len(_antlr.toks)-1]
+	}
+	return &_antlr.toks[_antlr.next+i]
+}
+
+// the token before the current token
+func aprev() *atoken {
+	if _antlr.next == 0 {
+		return atok()
+	}
+	return &_antlr.toks[_antlr.next-1]
+}
+
+// move on to the next token, never past the end of file
+func aadvance() {
+	if atok().kind != ATEOF {
+		_antlr.next++
+	}
+}
+
+// consume a token of the given kind if it is there
+func aaccept(kind int) bool {
+	if atok().kind == kind {
+		aadvance()
+		return true
+	}
+	return false
+}
+
+// skip tokens up to and including the next semicolon
+func askipstatement() {
+	for atok().kind != ';' && atok().kind != ATEOF {
+		aadvance()
+	}
+	aaccept(';')
+}
+
+// convert an ANTLR literal to a gtools terminal symbol, which has no
+// escapes, so a literal containing ' is put in double quotes
+func aliteral(text string) string {
+	var body string
+
+	body = aunescape(text)
+	if !strings.Contains(body, `'`) {
+		return "'" + body + "'"
+	}
+	if strings.Contains(body, `"`) {
+		errorin("LITERAL HOLDS BOTH KINDS OF QUOTE", atok().line, atok().pos)
+	}
+	return `"` + body + `"`
+}
+
+// the text an ANTLR literal stands for, its escapes replaced by what they
+// stand for; a line break can't be in a symbol, so its escape is kept as
+// it stands
+func aunescape(text string) string {
+	var b strings.Builder
+	var body string
+	var c byte
+
+	body = text[1 : len(text)-1]
+	for i := 0; i < len(body); i++ {
+		c = body[i]
+		if c != '\\' || i+1 == len(body) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch c = body[i]; c {
+		case 't':
+			b.WriteByte('\t')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n', 'r':
+			errorin("LITERAL HOLDS A LINE BREAK", atok().line, atok().pos)
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case 'u':
+			hex := body[i+1:]
+			if strings.HasPrefix(hex, "{") && strings.Contains(hex, "}") {
+				hex = hex[1:strings.Index(hex, "}")]
+				i = i + len(hex) + 2
+			} else {
+				hex = hex[:min(4, len(hex))]
+				i = i + len(hex)
+			}
+			r, err := strconv.ParseUint(hex, 16, 32)
+			if err != nil || len(hex) == 0 {
+				errorin("BAD ESCAPE IN LITERAL", atok().line, atok().pos)
+			}
+			b.WriteRune(rune(r))
+		default: // \\ \' and the rest stand for themselves
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// get a sequence of alternatives, up to ) or ;, leaving out those that
+// can't be read, so there may be none
+func aalts() *gnode {
+	var t *gnode
+
+	t = &gnode{kind: GALT, line: atok().line}
+	for {
+		if alt := aalt(); alt != nil {
+			t.kids = append(t.kids, alt)
+		}
+		if !aaccept('|') {
+			break
+		}
+	}
+	return t
+}
+
+// get one alternative, stopping at | ) or ;, returns nil if it holds
+// anything that can't be read as EBNF, since leaving that out would
+// change what the alternative matches
+func aalt() *gnode {
+	var t *gnode
+	var e *gnode
+	var ok = true
+
+	t = &gnode{kind: GSEQ, line: atok().line}
+	for {
+		switch atok().kind {
+		case '|', ')', ';', ATEOF:
+			if !ok {
+				return nil
+			}
+			return t
+		case '#': // alternative label
+			aadvance()
+			aaccept(ATID)
+			continue
+		case ATACTION: // action or semantic predicate
+			aadvance()
+			aaccept('?')
+			continue
+		case ATOPTS: // element options
+			aadvance()
+			continue
+		}
+		e = aelement()
+		if e == nil {
+			ok = false
+		} else {
+			t.kids = append(t.kids, e)
+		}
+	}
+}
+
+// get one element with any suffix, returns nil if it can't be read
+func aelement() *gnode {
+	var t *gnode
+	var tok *atoken
+	var ln int
+	var start span
+
+	tok = atok()
+	ln = tok.line
+	start = tok.pos
+	line = ln // for any symbols defined here
+
+	// labels, x=atom or x+=atom
+	if tok.kind == ATID && (alook(1).kind == '=' || alook(1).kind == ATPLUSEQ) {
+		aadvance()
+		aadvance()
+		tok = atok()
+	}
+
+	switch tok.kind {
+	case ATID:
+		t = &gnode{kind: GSYMBOL, sym: seen(namedsym(tok.text), tok.pos), line: ln, pos: tok.pos}
+		aadvance()
+		aaccept(ATARGS) // rule arguments
+		aaccept(ATOPTS)
+	case ATSTRING:
+		if alook(1).kind == ATRANGE && alook(2).kind == ATSTRING { // 'a'..'z'
+			t = grange(aunescape(tok.text), aunescape(alook(2).text), ln, spanto(start, alook(2).pos))
+			aadvance()
+			aadvance()
+			aadvance()
+			aaccept(ATOPTS)
+			break
+		}
+		t = &gnode{kind: GSYMBOL, sym: seen(namedsym(aliteral(tok.text)), tok.pos), line: ln, pos: tok.pos}
+		aadvance()
+		aaccept(ATOPTS)
+	case '(':
+		aadvance()
+		if atok().kind == ATID && atok().text == "options" {
+			aadvance()
+			aaccept(ATACTION)
+			aaccept(':')
+		}
+		t = aalts()
+		if !aaccept(')') {
+			errorin("MISSING )", atok().line, atok().pos)
+		}
+		t.pos = spanto(start, aprev().pos)
+		if len(t.kids) == 0 { // none of it could be read
+			askipsuffix()
+			return nil
+		}
+	case '.':
+		errorin("ANTLR WILDCARD NOT SUPPORTED", ln, tok.pos)
+		aadvance()
+		aaccept(ATOPTS)
+		askipsuffix()
+		return nil
+	case '~':
+		errorin("ANTLR NOT SET NOT SUPPORTED", ln, tok.pos)
+		aadvance()
+		aelement()
+		askipsuffix()
+		return nil
+	default:
+		errorin("UNEXPECTED "+tok.text, ln, tok.pos)
+		aadvance()
+		return nil
+	}
+
+	// suffixes, ignoring the ? that marks them non-greedy
+	switch atok().kind {
+	case '?':
+		aadvance()
+		aaccept('?')
+		t = &gnode{kind: GOPT, kids: aalternatives(t), line: ln, pos: spanto(start, aprev().pos)}
+	case '*':
+		aadvance()
+		aaccept('?')
+		t = &gnode{kind: GREP, kids: aalternatives(t), line: ln, pos: spanto(start, aprev().pos)}
+	case '+':
+		aadvance()
+		aaccept('?')
+		t = &gnode{kind: GSEQ, kids: []*gnode{t, {kind: GREP, kids: aalternatives(t), line: ln, pos: spanto(start, aprev().pos)}}, line: ln}
+	}
+	return t
+}
+
+// skip the suffix of an element that can't be read
+func askipsuffix() {
+	if atok().kind == '?' || atok().kind == '*' || atok().kind == '+' {
+		aadvance()
+		aaccept('?')
+	}
+}
+
+// the alternatives of t, which has a suffix applied to it
+func aalternatives(t *gnode) []*gnode {
+	if t.kind == GALT {
+		return t.kids
+	}
+	return []*gnode{{kind: GSEQ, kids: []*gnode{t}, line: t.line}}
+}
+
+// get a parser rule, the name has been consumed
+func aparserrule(s PSYMBOL) {
+	var t *gnode
+
+	// rule prequel, all of which is ignored
+	aaccept(ATARGS)
+	for atok().kind == ATID {
+		switch atok().text {
+		case "returns", "locals":
+			aadvance()
+			aaccept(ATARGS)
+			continue
+		case "throws":
+			aadvance()
+			for aaccept(ATID) && aaccept(',') {
+			}
+			continue
+		case "options":
+			aadvance()
+			aaccept(ATACTION)
+			continue
+		}
+		break
+	}
+	for aaccept('@') {
+		aaccept(ATID)
+		aaccept(ATACTION)
+	}
+
+	if !aaccept(':') {
+		errorin("MISSING : AFTER RULE NAME", atok().line, atok().pos)
+		askipstatement()
+		return
+	}
+	t = aalts()
+	if !aaccept(';') {
+		errorin("MISSING ; AT END OF RULE", atok().line, atok().pos)
+		askipstatement()
+	}
+
+	// exception handlers
+	for atok().kind == ATID && (atok().text == "catch" || atok().text == "finally") {
+		aadvance()
+		aaccept(ATARGS)
+		aaccept(ATACTION)
+	}
+
+	if s.data != nil {
+		errormsg("RULE DEFINED TWICE", t.line)
+	}
+	if len(t.kids) == 0 { // it stays a token, defined elsewhere
+		errormsg("NO ALTERNATIVE OF RULE CAN BE READ", t.line)
+	}
+	treetorules(s, t)
+	if head == nil {
+		head = s
+	}
+}
+
+// readantlr: read ANTLR grammar into global grammar structure in grammar.h
+func readantlr() {
+	var tok *atoken
+
+	newgrammar()
+	_antlr.src = readall()
+	_antlr.pos = 0
+	_antlr.line = 1
+	_antlr.toks = nil
+	_antlr.next = 0
+	alex()
+
+	for atok().kind != ATEOF {
+		tok = atok()
+		if tok.kind != ATID {
+			if tok.kind == '@' { // named action
+				aadvance()
+				aaccept(ATID)
+				if aaccept(ATCOLONS) {
+					aaccept(ATID)
+				}
+				aaccept(ATACTION)
+			} else {
+				errorin("UNEXPECTED "+tok.text, tok.line, tok.pos)
+				aadvance()
+			}
+			continue
+		}
+
+		switch tok.text {
+		case "lexer", "parser", "grammar", "import", "mode":
+			askipstatement()
+		case "options", "tokens", "channels":
+			aadvance()
+			aaccept(ATACTION)
+		case "fragment":
+			askipstatement()
+		default:
+			if 'A' <= tok.text[0] && tok.text[0] <= 'Z' {
+				// a lexer rule, its token stays a terminal
+				askipstatement()
+			} else {
+				aadvance()
+				line = tok.line
+				aparserrule(seen(namedsym(tok.text), tok.pos))
+			}
+		}
+	}
+
+	if head == nil {
+		errormsg("NO PARSER RULES", -1)
+	}
+	line = -1 // mark any new line numbers as fictional
+}
+
+// ANTLR output
+
+// words that can't be used as rule names
+var antlrkeywords = map[string]bool{
+	"catch": true, "channels": true, "finally": true, "fragment": true,
+	"grammar": true, "import": true, "lexer": true, "locals": true,
+	"mode": true, "options": true, "parser": true, "returns": true,
+	"throws": true, "tokens": true,
+}
+
+// names for punctuation in generated token names
+var antlrpunct = map[byte]string{
+	'!': "BANG", '"': "DQUOTE", '#': "HASH", '$': "DOLLAR", '%': "PERCENT",
+	'&': "AMP", '\'': "QUOTE", '(': "LPAREN", ')': "RPAREN", '*': "STAR",
+	'+': "PLUS", ',': "COMMA", '-': "MINUS", '.': "DOT", '/': "SLASH",
+	':': "COLON", ';': "SEMI", '<': "LT", '=': "EQ", '>': "GT",
+	'?': "QUESTION", '@': "AT", '[': "LBRACK", '\\': "BACKSLASH",
+	']': "RBRACK", '^': "CARET", '`': "BACKQUOTE", '{': "LBRACE",
+	'|': "BAR", '}': "RBRACE", '~': "TILDE",
+}
+
+// isquoted reports whether a symbol name is in quotes
+func isquoted(name string) bool {
+	return len(name) >= 2 && (name[0] == '\'' || name[0] == '"') && name[len(name)-1] == name[0]
+}
+
+// isbracketed reports whether a symbol name is in <angle brackets>
+func isbracketed(name string) bool {
+	return len(name) >= 3 && name[0] == '<' && name[len(name)-1] == '>'
+}
+
+// identifier turns a symbol name into an identifier, stripping brackets
+// or quotes and replacing anything that isn't a letter or digit with _;
+// returns "" if there is nothing alphanumeric in the name
+func identifier(name string) string {
+	var b strings.Builder
+	var any bool
+
+	if isbracketed(name) || isquoted(name) {
+		name = name[1 : len(name)-1]
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') {
+			b.WriteByte(c)
+			any = true
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	if !any {
+		return ""
+	}
+	return b.String()
+}
+
+// give each name in used a distinct name, adding a numeric suffix if needed
+func uniquename(name string, used map[string]bool) string {
+	var try string
+
+	try = name
+	for n := 2; used[try]; n++ {
+		try = name + "_" + itoa(n)
+	}
+	used[try] = true
+	return try
+}
+
+func itoa(n int) string {
+	var buf [20]byte
+	var i int
+
+	i = len(buf)
+	for {
+		i--
+		buf[i] = byte('0' + n%10)
+		n = n / 10
+		if n == 0 {
+			break
+		}
+	}
+	return string(buf[i:])
+}
+
+// punctuation token name for a name with no letters or digits
+func punctname(name string) string {
+	var parts []string
+
+	for i := 0; i < len(name); i++ {
+		if p, ok := antlrpunct[name[i]]; ok {
+			parts = append(parts, p)
+		} else {
+			parts = append(parts, "X"+strings.ToUpper(strings.TrimLeft(itox(name[i]), "0")))
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+func itox(c byte) string {
+	const digits = "0123456789abcdef"
+	return string([]byte{digits[c>>4], digits[c&15]})
+}
+
+// antlrquote puts the body of a gtools quoted terminal in ANTLR quotes,
+// escaping every quote and backslash in it, and any control character
+func antlrquote(body string) string {
+	var b strings.Builder
+
+	b.WriteByte('\'')
+	for _, r := range body {
+		switch {
+		case r == '\'' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < ' ' || r == 0x7f:
+			b.WriteString(`\u00` + itox(byte(r)))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// global variables private to this package
+var _antlrout struct {
+	names   map[PSYMBOL]string // ANTLR text for each symbol
+	lexer   []PSYMBOL          // terminals that need a lexer rule
+	tokens  []string           // token references with no lexer rule
+	contcol int                // column number for continuation
+}
+
+// decide on the ANTLR text for every symbol
+func antlrnames() {
+	var s PSYMBOL
+	var used map[string]bool
+	var name, id string
+
+	_antlrout.names = map[PSYMBOL]string{}
+	_antlrout.lexer = nil
+	_antlrout.tokens = nil
+	used = map[string]bool{}
+
+	// rule names first, so they keep the simplest names
+	for s = symlist; s != nil; s = s.next {
+		if NONTERMINAL(s) {
+			id = identifier(symname(s))
+			if id == "" || !('a' <= id[0] && id[0] <= 'z' || 'A' <= id[0] && id[0] <= 'Z') {
+				id = "r_" + id
+			}
+			id = strings.ToLower(id[:1]) + id[1:]
+			if antlrkeywords[id] {
+				id = id + "_"
+			}
+			_antlrout.names[s] = uniquename(id, used)
+		}
+	}
+
+	for s = symlist; s != nil; s = s.next {
+		if NONTERMINAL(s) {
+			continue
+		}
+		name = symname(s)
+		if s == emptypt {
+			_antlrout.names[s] = ""
+		} else if _, _, ok := metakind(s); ok {
+			_antlrout.names[s] = name
+		} else if isquoted(name) && len(name) > 2 {
+			_antlrout.names[s] = antlrquote(name[1 : len(name)-1])
+		} else if id = identifier(name); id != "" && !isquoted(name) {
+			if !('A' <= id[0] && id[0] <= 'Z' || 'a' <= id[0] && id[0] <= 'z') {
+				id = "T_" + id
+			}
+			id = uniquename(strings.ToUpper(id), used)
+			_antlrout.names[s] = id
+			if id != "EOF" { // ANTLR defines EOF itself
+				_antlrout.tokens = append(_antlrout.tokens, id)
+			}
+		} else {
+			// punctuation, or something quoted with nothing in the quotes
+			if isquoted(name) {
+				errormsg("EMPTY LITERAL "+name+" USED AS TOKEN", s.line)
+				id = uniquename("EMPTY", used)
+				_antlrout.tokens = append(_antlrout.tokens, id)
+			} else {
+				id = uniquename(punctname(name), used)
+				_antlrout.lexer = append(_antlrout.lexer, s)
+			}
+			_antlrout.names[s] = id
+		}
+	}
+}
+
+// put out one ANTLR word, wrapping long lines
+func antlrword(w string) {
+	if w == "" {
+		return
+	}
+	outspacelen(strwidth(w), _antlrout.contcol, ' ')
+	outstr(w)
+}
+
+// put out the elements of production p in ANTLR notation,
+// returning the bracket nesting depth at the end of p
+func antlrprod(p PPRODUCTION, nest int) int {
+	var e PELEMENT
+
+	for e = p.data; e != nil; e = e.next {
+		if kind, open, ok := metakind(e.data); ok {
+			if open {
+				nest++
+				antlrword("(")
+				continue
+			}
+			nest--
+			if kind == GOPT {
+				antlrword(")?")
+			} else if kind == GREP {
+				antlrword(")*")
+			} else {
+				antlrword(")")
+			}
+		} else {
+			antlrword(_antlrout.names[e.data])
+		}
+	}
+	return nest
+}
+
+// writeantlr
+// write grammar structure as an ANTLR4 combined grammar called name
+func writeantlr(name string) {
+	var p PPRODUCTION
+	var nest int    // bracket nesting depth
+	var header bool // the unused rules have been headed
+	var order []PSYMBOL
+
+	outsetup()
+	antlrnames()
+
+	outstr("grammar ")
+	outstr(name)
+	outchar(';')
+	outline()
+
+	if len(_antlrout.tokens) != 0 {
+		// declared, so the parser rules can refer to them
+		outline()
+		outstr("// tokens defined elsewhere")
+		outline()
+		outstr("tokens {")
+		for i, t := range _antlrout.tokens {
+			if i > 0 {
+				outchar(',')
+			}
+			if getoutcol()+2+len(t) > 80 {
+				outline()
+				outstr("   ")
+			}
+			outchar(' ')
+			outstr(t)
+		}
+		outstr(" }")
+		outline()
+	}
+
+	order = reachorder()
+	for _, s := range order {
+		if s.state == UNTOUCHED && head != nil && !header {
+			outline()
+			outstr("// unused rules")
+			outline()
+			header = true
+		}
+		outline()
+		outstr(_antlrout.names[s])
+		outline()
+		outstr("    :")
+		_antlrout.contcol = 6
+		nest = 0
+		for p = s.data; p != nil; p = p.next {
+			if p == s.data {
+				// first alternative follows the colon
+			} else if nest > 0 { // a bar inside brackets
+				antlrword("|")
+			} else {
+				outline()
+				outstr("    |")
+			}
+			nest = antlrprod(p, nest)
+		}
+		outline()
+		outstr("    ;")
+		outline()
+	}
+
+	for _, s := range _antlrout.lexer {
+		outline()
+		outstr(_antlrout.names[s])
+		outstr(" : ")
+		outstr(antlrquote(symname(s)))
+		outstr(" ;")
+		outline()
+	}
+}
@@ -0,0 +1,135 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// convert reads src with read and returns what write puts out, along
+// with the messages of any errors reported
+func convert(src string, read func(), write func()) (string, []string) {
+	var out bytes.Buffer
+	var errs []string
+
+	SetStdinReader(strings.NewReader(src))
+	SetStdout(&out)
+	defer SetStdout(os.Stdout)
+	SetDiagnostics(func(d Diagnostic) {
+		errs = append(errs, d.Message)
+	})
+	defer SetDiagnostics(nil)
+
+	read()
+	write()
+	return out.String(), errs
+}
+
+// an ANTLR grammar read and written back is the same grammar, with the
+// tokens it doesn't define declared and its literals escaped
+func TestANTLRRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"subrules",
+			"grammar Expr;\n" +
+				"expr : term (('+' | '-') term)* ;\n" +
+				"term : NUMBER | '(' expr ')' | ID? ;\n",
+			"grammar Expr;\n" +
+				"\n" +
+				"// tokens defined elsewhere\n" +
+				"tokens { NUMBER, ID }\n" +
+				"\n" +
+				"expr\n" +
+				"    : term ( ( '+' | '-' ) term )*\n" +
+				"    ;\n" +
+				"\n" +
+				"term\n" +
+				"    : NUMBER\n" +
+				"    | '(' expr ')'\n" +
+				"    | ( ID )?\n" +
+				"    ;\n"},
+		{"literals",
+			"grammar Lit;\n" +
+				"s : 'a\\\\b' 'it\\'s' '\\u0041\\t' ;\n",
+			"grammar Lit;\n" +
+				"\n" +
+				"s\n" +
+				"    : 'a\\\\b' 'it\\'s' 'A\\t'\n" +
+				"    ;\n"},
+		{"ranges",
+			"grammar Range;\n" +
+				"s : 'a'..'c' 'x' ;\n",
+			"grammar Range;\n" +
+				"\n" +
+				"s\n" +
+				"    : ( 'a' | 'b' | 'c' ) 'x'\n" +
+				"    ;\n"},
+		{"unused",
+			"grammar Unused;\n" +
+				"s : 'x' ;\n" +
+				"t : s ;\n" +
+				"X : 'x' ;\n",
+			"grammar Unused;\n" +
+				"\n" +
+				"s\n" +
+				"    : 'x'\n" +
+				"    ;\n" +
+				"\n" +
+				"// unused rules\n" +
+				"\n" +
+				"t\n" +
+				"    : s\n" +
+				"    ;\n"},
+	} {
+		got, errs := convert(tc.src, ReadANTLR, func() { WriteANTLR(grammarname(tc.src)) })
+		if len(errs) != 0 {
+			t.Errorf("%s: unexpected errors %v", tc.name, errs)
+		}
+		if got != tc.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tc.name, got, tc.want)
+		}
+	}
+}
+
+// the name of the grammar declared on the first line of src
+func grammarname(src string) string {
+	first, _, _ := strings.Cut(src, ";")
+	return strings.TrimPrefix(first, "grammar ")
+}
+
+// an alternative holding what EBNF can't say is reported and left out
+// whole, rather than written as something that matches other strings
+func TestANTLRUnsupported(t *testing.T) {
+	src := "grammar Not;\n" +
+		"s : t ';' | 'x' ~';' | ( 'y' | . )* 'z' ;\n" +
+		"t : ~';' ;\n"
+	want := "grammar Not;\n" +
+		"\n" +
+		"// tokens defined elsewhere\n" +
+		"tokens { T }\n" +
+		"\n" +
+		"s\n" +
+		"    : T ';'\n" +
+		"    | ( 'y' )* 'z'\n" +
+		"    ;\n"
+	wanterrs := []string{
+		"ANTLR NOT SET NOT SUPPORTED",
+		"ANTLR WILDCARD NOT SUPPORTED",
+		"ANTLR NOT SET NOT SUPPORTED",
+		"NO ALTERNATIVE OF RULE CAN BE READ",
+	}
+	got, errs := convert(src, ReadANTLR, func() { WriteANTLR(grammarname(src)) })
+	if strings.Join(errs, "\n") != strings.Join(wanterrs, "\n") {
+		t.Errorf("got errors %v, want %v", errs, wanterrs)
+	}
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -6,9 +6,12 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"github.com/mdhender/gtools"
+	"io"
 	"log"
+	"os"
 )
 
 // written by Douglas Jones, July 2013,
@@ -17,10 +20,50 @@ import (
 
 // main program to copy a grammar
 func main() {
-	var input string
+	var input, order, diagformat string
+	var dropunused bool
+	var align string
+	var check bool
+	format := gtools.DefaultFormat()
 	flag.StringVar(&input, "input", input, "grammar to process")
+	flag.StringVar(&diagformat, "format", "text", "format of error messages, text, json or sarif")
+	flag.StringVar(&order, "order", "reach", "order of the rules, reach, source or alpha")
+	flag.BoolVar(&dropunused, "dropunused", dropunused, "leave out rules that can't be reached")
+	flag.IntVar(&format.Width, "width", format.Width, "wrap lines before this column, 0 for no wrapping")
+	flag.StringVar(&format.RuleSym, "rulesym", format.RuleSym, "rule symbol, ::= = : or :=")
+	flag.StringVar(&align, "align", "rule", "put the bars under the rule symbol (rule) or at -indent (indent)")
+	flag.IntVar(&format.Indent, "indent", format.Indent, "column of the bars with -align indent")
+	flag.BoolVar(&format.OneAlt, "onealt", format.OneAlt, "put each alternative on a line of its own")
+	flag.BoolVar(&format.Period, "period", format.Period, "end each rule with a period, as Wirth did")
+	flag.BoolVar(&check, "check", check, "report whether the grammar is formatted, instead of copying it")
 	flag.Parse()
 
+	if err := gtools.SetDiagnosticFormat(diagformat); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := gtools.SetWriteOrder(order); err != nil {
+		log.Fatal(err)
+	}
+	gtools.SetDropUnused(dropunused)
+	switch align {
+	case "rule":
+		format.Align = gtools.ALIGNRULE
+	case "indent":
+		format.Align = gtools.ALIGNINDENT
+	default:
+		log.Fatalf("unknown alignment %q", align)
+	}
+	if err := gtools.SetFormat(format); err != nil {
+		log.Fatal(err)
+	}
+
+	if check {
+		status := checkformat(input)
+		gtools.WriteDiagnostics()
+		os.Exit(status)
+	}
+
 	if input != "" {
 		if err := gtools.SetStdin(input); err != nil {
 			log.Fatal(err)
@@ -29,6 +72,35 @@ func main() {
 
 	readg()
 	writeg()
+	gtools.WriteDiagnostics()
+}
+
+// checkformat copies the grammar to memory and compares the copy with
+// the original, returning the exit status
+func checkformat(input string) int {
+	var src []byte
+	var err error
+	var out bytes.Buffer
+
+	if input != "" {
+		src, err = os.ReadFile(input)
+	} else {
+		src, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	gtools.SetStdinReader(bytes.NewReader(src))
+	gtools.SetSourceName(input)
+	gtools.SetStdout(&out)
+	readg()
+	writeg()
+	if !bytes.Equal(src, out.Bytes()) {
+		gtools.Report(gtools.Diagnostic{Message: "NOT FORMATTED", File: input, Severity: "error"})
+		return 1
+	}
+	return 0
 }
 
 func readg() {
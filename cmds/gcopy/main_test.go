@@ -0,0 +1,66 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mdhender/gtools"
+)
+
+// a copy of each example grammar is one that -check accepts, so copying
+// a copy changes nothing
+func TestCopyIsFormatted(t *testing.T) {
+	var out bytes.Buffer
+
+	names, err := filepath.Glob(filepath.Join("..", "..", "grammars", "*.gr"))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(names) == 0 {
+		t.Fatal("no grammars found")
+	}
+	gtools.SetDiagnostics(func(gtools.Diagnostic) {}) // the errors of errors.gr are not what is tested
+	defer gtools.SetDiagnostics(nil)
+	for _, name := range names {
+		out.Reset()
+		if err = gtools.SetStdin(name); err != nil {
+			t.Fatal(err)
+		}
+		gtools.SetStdout(&out)
+		readg()
+		writeg()
+
+		copied := filepath.Join(t.TempDir(), filepath.Base(name))
+		if err = os.WriteFile(copied, out.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if checkformat(copied) != 0 {
+			t.Errorf("%s: the copy is not formatted", name)
+		}
+	}
+	gtools.SetStdout(os.Stdout)
+}
+
+// a grammar that is not formatted is reported as an error in it
+func TestCheckReports(t *testing.T) {
+	var diags []gtools.Diagnostic
+
+	name := filepath.Join(t.TempDir(), "messy.gr")
+	if err := os.WriteFile(name, []byte("> s\n\ns ::=   x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gtools.SetDiagnostics(func(d gtools.Diagnostic) {
+		diags = append(diags, d)
+	})
+	defer gtools.SetDiagnostics(nil)
+	if checkformat(name) == 0 {
+		t.Errorf("%s: reported as formatted", name)
+	}
+	if len(diags) != 1 || diags[0].Message != "NOT FORMATTED" || diags[0].File != name {
+		t.Errorf("got %v, want NOT FORMATTED in %s", diags, name)
+	}
+}
@@ -0,0 +1,55 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+// Package main implements the command line tool gdiff
+// to report the differences between two grammars.
+package main
+
+import (
+	"flag"
+	"github.com/mdhender/gtools"
+	"log"
+	"os"
+)
+
+func main() {
+	var normalise, bnf bool
+	var diagformat string
+	flag.BoolVar(&normalise, "normalise", normalise, "compare after gdeebnf and gsqueeze, so notation doesn't matter")
+	flag.BoolVar(&bnf, "bnf", bnf, "treat ( ) [ ] { } as terminals, not EBNF brackets")
+	flag.StringVar(&diagformat, "format", "text", "format of error messages, text, json or sarif")
+	flag.Usage = func() {
+		_, _ = os.Stderr.WriteString("usage: gdiff [-normalise] [-bnf] [-format f] old.gr new.gr\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	gtools.SetPlainBNF(bnf)
+	if err := gtools.SetDiagnosticFormat(diagformat); err != nil {
+		log.Fatal(err)
+	}
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	readg(flag.Arg(0), normalise)
+	gtools.RememberGrammar()
+	readg(flag.Arg(1), normalise)
+	changed := gtools.DiffGrammar()
+	gtools.WriteDiagnostics()
+	if changed {
+		os.Exit(1)
+	}
+}
+
+// read the grammar in the named file, normalised if asked
+func readg(input string, normalise bool) {
+	if err := gtools.SetStdin(input); err != nil {
+		log.Fatal(err)
+	}
+	gtools.ReadGrammar()
+	if normalise {
+		gtools.GDeEBNF()
+		gtools.Squeeze()
+	}
+}
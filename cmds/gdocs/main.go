@@ -0,0 +1,41 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+// Package main implements the command line tool gdocs
+// to write cross linked documentation of a BNF or EBNF grammar.
+package main
+
+import (
+	"flag"
+	"github.com/mdhender/gtools"
+	"log"
+)
+
+func main() {
+	var input, docformat, title, diagformat string
+	var bnf bool
+	flag.StringVar(&input, "input", input, "grammar to process")
+	flag.StringVar(&diagformat, "format", "text", "format of error messages, text, json or sarif")
+	flag.StringVar(&docformat, "docformat", "markdown", "format to write, markdown or html")
+	flag.StringVar(&title, "title", "Grammar", "title of the document")
+	flag.BoolVar(&bnf, "bnf", bnf, "treat ( ) [ ] { } as terminals, not EBNF brackets")
+	flag.Parse()
+
+	if err := gtools.SetDiagnosticFormat(diagformat); err != nil {
+		log.Fatal(err)
+	}
+
+	if docformat != "markdown" && docformat != "html" {
+		log.Fatalf("unknown format %q", docformat)
+	}
+	if input != "" {
+		if err := gtools.SetStdin(input); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	gtools.SetPlainBNF(bnf)
+	gtools.ReadGrammar()
+	gtools.WriteDocs(docformat, title)
+	gtools.WriteDiagnostics()
+}
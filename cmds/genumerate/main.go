@@ -0,0 +1,40 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+// Package main implements the command line tool genumerate
+// to list every string a BNF grammar derives, up to a length or a depth.
+package main
+
+import (
+	"flag"
+	"github.com/mdhender/gtools"
+	"log"
+)
+
+func main() {
+	var input, start, diagformat string
+	var length, depth int
+	flag.StringVar(&input, "input", input, "grammar to process")
+	flag.StringVar(&diagformat, "format", "text", "format of error messages, text, json or sarif")
+	flag.IntVar(&length, "length", length, "list the strings of up to this many tokens, 0 for no limit")
+	flag.IntVar(&depth, "depth", depth, "list the strings of derivations up to this deep, 0 for no limit")
+	flag.StringVar(&start, "start", start, "list the strings of this symbol (default the distinguished symbol)")
+	flag.Parse()
+
+	if err := gtools.SetDiagnosticFormat(diagformat); err != nil {
+		log.Fatal(err)
+	}
+
+	if input != "" {
+		if err := gtools.SetStdin(input); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	gtools.ReadGrammar()
+	err := gtools.Enumerate(start, length, depth)
+	gtools.WriteDiagnostics()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
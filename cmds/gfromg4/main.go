@@ -0,0 +1,33 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+// Package main implements the command line tool gfromg4
+// to convert the parser rules of an ANTLR4 grammar to EBNF.
+package main
+
+import (
+	"flag"
+	"github.com/mdhender/gtools"
+	"log"
+)
+
+func main() {
+	var input, diagformat string
+	flag.StringVar(&input, "input", input, "ANTLR4 grammar to process")
+	flag.StringVar(&diagformat, "format", "text", "format of error messages, text, json or sarif")
+	flag.Parse()
+
+	if err := gtools.SetDiagnosticFormat(diagformat); err != nil {
+		log.Fatal(err)
+	}
+
+	if input != "" {
+		if err := gtools.SetStdin(input); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	gtools.ReadANTLR()
+	gtools.WriteGrammar()
+	gtools.WriteDiagnostics()
+}
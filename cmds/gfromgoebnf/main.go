@@ -0,0 +1,34 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+// Package main implements the command line tool gfromgoebnf
+// to convert a grammar in the EBNF of the Go specification to gtools EBNF.
+package main
+
+import (
+	"flag"
+	"github.com/mdhender/gtools"
+	"log"
+)
+
+func main() {
+	var input, start, diagformat string
+	flag.StringVar(&input, "input", input, "Go EBNF grammar to process")
+	flag.StringVar(&diagformat, "format", "text", "format of error messages, text, json or sarif")
+	flag.StringVar(&start, "start", start, "distinguished symbol (default first production)")
+	flag.Parse()
+
+	if err := gtools.SetDiagnosticFormat(diagformat); err != nil {
+		log.Fatal(err)
+	}
+
+	if input != "" {
+		if err := gtools.SetStdin(input); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	gtools.ReadGoEBNF(start)
+	gtools.WriteGrammar()
+	gtools.WriteDiagnostics()
+}
@@ -0,0 +1,41 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+// Package main implements the command line tool gfromjson
+// to convert a grammar written as JSON by gtojson back to BNF or EBNF.
+package main
+
+import (
+	"flag"
+	"github.com/mdhender/gtools"
+	"log"
+)
+
+func main() {
+	var input, order, diagformat string
+	var dropunused bool
+	flag.StringVar(&input, "input", input, "JSON grammar to process")
+	flag.StringVar(&diagformat, "format", "text", "format of error messages, text, json or sarif")
+	flag.StringVar(&order, "order", "reach", "order of the rules, reach, source or alpha")
+	flag.BoolVar(&dropunused, "dropunused", dropunused, "leave out rules that can't be reached")
+	flag.Parse()
+
+	if err := gtools.SetDiagnosticFormat(diagformat); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := gtools.SetWriteOrder(order); err != nil {
+		log.Fatal(err)
+	}
+	gtools.SetDropUnused(dropunused)
+
+	if input != "" {
+		if err := gtools.SetStdin(input); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	gtools.ReadJSON()
+	gtools.WriteGrammar()
+	gtools.WriteDiagnostics()
+}
@@ -0,0 +1,69 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+// Package main implements the command line tool glint
+// to check a grammar for things that are probably mistakes.
+package main
+
+import (
+	"flag"
+	"github.com/mdhender/gtools"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	var input, disable, diagformat string
+	var bnf, period, list bool
+	var found []gtools.Diagnostic
+	flag.StringVar(&input, "input", input, "grammar to process")
+	flag.StringVar(&diagformat, "format", "text", "format of the problems found, text, json or sarif")
+	flag.StringVar(&disable, "disable", disable, "checks to leave out, separated by commas")
+	flag.BoolVar(&bnf, "bnf", bnf, "treat ( ) [ ] { } as terminals, not EBNF brackets")
+	flag.BoolVar(&period, "period", period, "rules end with a period, as Wirth did")
+	flag.BoolVar(&list, "list", list, "list the checks and stop")
+	flag.Parse()
+
+	if list {
+		gtools.WriteLintChecks()
+		return
+	}
+	if diagformat != "text" && diagformat != "json" && diagformat != "sarif" {
+		log.Fatalf("unknown format %q", diagformat)
+	}
+
+	format := gtools.DefaultFormat()
+	format.Period = period
+	if err := gtools.SetFormat(format); err != nil {
+		log.Fatal(err)
+	}
+	gtools.SetPlainBNF(bnf)
+	if input != "" {
+		if err := gtools.SetStdin(input); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// the errors of readg are reported along with the problems
+	gtools.SetDiagnostics(func(d gtools.Diagnostic) {
+		found = append(found, d)
+	})
+	gtools.ReadGrammar()
+	var ids []string
+	if disable != "" {
+		ids = strings.Split(disable, ",")
+	}
+	problems, err := gtools.Lint(ids)
+	if err != nil {
+		log.Fatal(err)
+	}
+	found = append(found, problems...)
+
+	if err := gtools.FormatDiagnostics(os.Stdout, diagformat, found); err != nil {
+		log.Fatal(err)
+	}
+	if len(found) != 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,24 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+// Package main implements the command line tool glsp
+// to serve the Language Server Protocol for grammars on stdin and stdout.
+package main
+
+import (
+	"flag"
+	"github.com/mdhender/gtools"
+	"log"
+	"os"
+)
+
+func main() {
+	var bnf bool
+	flag.BoolVar(&bnf, "bnf", bnf, "treat ( ) [ ] { } as terminals, not EBNF brackets")
+	flag.Parse()
+
+	gtools.SetPlainBNF(bnf)
+	if err := gtools.ServeLSP(os.Stdin, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
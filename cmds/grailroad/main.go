@@ -0,0 +1,43 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+// Package main implements the command line tool grailroad
+// to draw railroad diagrams of a BNF or EBNF grammar as SVG.
+package main
+
+import (
+	"flag"
+	"github.com/mdhender/gtools"
+	"log"
+)
+
+func main() {
+	var input, dir, title, diagformat string
+	var bnf, html bool
+	flag.StringVar(&input, "input", input, "grammar to process")
+	flag.StringVar(&diagformat, "format", "text", "format of error messages, text, json or sarif")
+	flag.StringVar(&dir, "dir", ".", "directory to write the SVG files in")
+	flag.BoolVar(&html, "html", html, "write one HTML page with all diagrams to stdout")
+	flag.StringVar(&title, "title", "Grammar", "title of the HTML page")
+	flag.BoolVar(&bnf, "bnf", bnf, "treat ( ) [ ] { } as terminals, not EBNF brackets")
+	flag.Parse()
+
+	if err := gtools.SetDiagnosticFormat(diagformat); err != nil {
+		log.Fatal(err)
+	}
+
+	if input != "" {
+		if err := gtools.SetStdin(input); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	gtools.SetPlainBNF(bnf)
+	gtools.ReadGrammar()
+	if html {
+		gtools.WriteRailroadHTML(title)
+	} else if err := gtools.WriteRailroadSVG(dir); err != nil {
+		log.Fatal(err)
+	}
+	gtools.WriteDiagnostics()
+}
@@ -0,0 +1,85 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+// Package main implements the command line tool grefactor
+// to rename, inline and extract the symbols of a grammar.
+package main
+
+import (
+	"flag"
+	"github.com/mdhender/gtools"
+	"log"
+	"os"
+	"strconv"
+)
+
+const usage = `usage: grefactor [flags] command arguments
+commands:
+  rename old new                  give the symbol old the name new
+  inline symbol                   replace each use of symbol with its rules
+  extract symbol rule from to new put elements from..to of the rule-th
+                                  alternative of symbol, those inside
+                                  brackets counted too, under the new
+                                  symbol new
+flags:
+`
+
+func main() {
+	var input, order, diagformat string
+	var dropunused, bnf bool
+	var err error
+	flag.StringVar(&input, "input", input, "grammar to process")
+	flag.StringVar(&diagformat, "format", "text", "format of error messages, text, json or sarif")
+	flag.StringVar(&order, "order", "reach", "order of the rules, reach, source or alpha")
+	flag.BoolVar(&dropunused, "dropunused", dropunused, "leave out rules that can't be reached")
+	flag.BoolVar(&bnf, "bnf", bnf, "treat ( ) [ ] { } as terminals, not EBNF brackets")
+	flag.Usage = func() {
+		_, _ = os.Stderr.WriteString(usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	gtools.SetPlainBNF(bnf)
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := gtools.SetDiagnosticFormat(diagformat); err != nil {
+		log.Fatal(err)
+	}
+	if err := gtools.SetWriteOrder(order); err != nil {
+		log.Fatal(err)
+	}
+	gtools.SetDropUnused(dropunused)
+
+	if input != "" {
+		if err := gtools.SetStdin(input); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	gtools.ReadGrammar()
+	switch {
+	case args[0] == "rename" && len(args) == 3:
+		err = gtools.RenameSymbol(args[1], args[2])
+	case args[0] == "inline" && len(args) == 2:
+		err = gtools.InlineSymbol(args[1])
+	case args[0] == "extract" && len(args) == 6:
+		var n [3]int
+		for i := range n {
+			if n[i], err = strconv.Atoi(args[2+i]); err != nil {
+				log.Fatalf("extract: %s is not a number", args[2+i])
+			}
+		}
+		err = gtools.ExtractRule(args[1], n[0], n[1], n[2], args[5])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	gtools.WriteDiagnostics()
+	if err != nil {
+		log.Fatal(err)
+	}
+	gtools.WriteGrammar()
+}
@@ -5,5 +5,61 @@
 // to generate an example string from a BNF grammar.
 package main
 
+import (
+	"flag"
+	"github.com/mdhender/gtools"
+	"log"
+)
+
 func main() {
+	var input, diagformat, weights string
+	var cover, pairs, listweights bool
+	var depth, length, mutants int
+	flag.StringVar(&input, "input", input, "grammar to process")
+	flag.StringVar(&diagformat, "format", "text", "format of error messages, text, json or sarif")
+	flag.BoolVar(&cover, "cover", cover, "write strings that between them use every rule, instead of one at random")
+	flag.BoolVar(&pairs, "pairs", pairs, "with -cover, use every rule for each symbol of every rule too")
+	flag.IntVar(&depth, "depth", depth, "with -cover, the deepest derivation to use, 0 for no limit")
+	flag.StringVar(&weights, "weights", weights, "file of weights to pick the rules by")
+	flag.IntVar(&length, "length", length, "balance the weights so the strings are this many tokens long on average")
+	flag.BoolVar(&listweights, "listweights", listweights, "write the weights of the rules instead of a string")
+	flag.IntVar(&mutants, "mutants", mutants, "write this many strings not in the language, made by mutating samples")
+	flag.Parse()
+
+	if err := gtools.SetDiagnosticFormat(diagformat); err != nil {
+		log.Fatal(err)
+	}
+
+	if input != "" {
+		if err := gtools.SetStdin(input); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	gtools.ReadGrammar()
+	if weights != "" {
+		if err := gtools.ReadWeights(weights); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if length > 0 {
+		if err := gtools.BalanceWeights(length); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if listweights {
+		gtools.WriteWeights()
+	} else if mutants > 0 {
+		err := gtools.SampleMutants(mutants)
+		gtools.WriteDiagnostics()
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	} else if cover || pairs {
+		gtools.SampleCover(depth, pairs)
+	} else {
+		gtools.Sample()
+	}
+	gtools.WriteDiagnostics()
 }
@@ -0,0 +1,45 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+// Package main implements the command line tool gshortest
+// to find the shortest string each non-terminal derives.
+package main
+
+import (
+	"flag"
+	"github.com/mdhender/gtools"
+	"log"
+)
+
+func main() {
+	var input, order, diagformat string
+	var derivations bool
+	flag.StringVar(&input, "input", input, "grammar to process")
+	flag.StringVar(&diagformat, "format", "text", "format of error messages, text, json or sarif")
+	flag.StringVar(&order, "order", "reach", "order of the rules, reach, source or alpha")
+	flag.BoolVar(&derivations, "derivations", derivations, "list the derivation of each string instead of writing the grammar")
+	flag.Parse()
+
+	if err := gtools.SetDiagnosticFormat(diagformat); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := gtools.SetWriteOrder(order); err != nil {
+		log.Fatal(err)
+	}
+
+	if input != "" {
+		if err := gtools.SetStdin(input); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	gtools.ReadGrammar()
+	if derivations {
+		gtools.WriteDerivations()
+	} else {
+		gtools.Shortest()
+		gtools.WriteGrammar()
+	}
+	gtools.WriteDiagnostics()
+}
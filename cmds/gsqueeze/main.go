@@ -12,10 +12,23 @@ import (
 )
 
 func main() {
-	var input string
+	var input, order, diagformat string
+	var dropunused bool
 	flag.StringVar(&input, "input", input, "grammar to process")
+	flag.StringVar(&diagformat, "format", "text", "format of error messages, text, json or sarif")
+	flag.StringVar(&order, "order", "reach", "order of the rules, reach, source or alpha")
+	flag.BoolVar(&dropunused, "dropunused", dropunused, "leave out rules that can't be reached")
 	flag.Parse()
 
+	if err := gtools.SetDiagnosticFormat(diagformat); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := gtools.SetWriteOrder(order); err != nil {
+		log.Fatal(err)
+	}
+	gtools.SetDropUnused(dropunused)
+
 	if input != "" {
 		if err := gtools.SetStdin(input); err != nil {
 			log.Fatal(err)
@@ -25,4 +38,5 @@ func main() {
 	gtools.ReadGrammar()
 	gtools.Squeeze()
 	gtools.WriteGrammar()
+	gtools.WriteDiagnostics()
 }
@@ -5,5 +5,42 @@
 // to find the start and follow sets of all nonterminals.
 package main
 
+import (
+	"flag"
+	"github.com/mdhender/gtools"
+	"log"
+)
+
+// written by Douglas Jones, July 2013,
+// based on pieces of cruncher, written in Pascal by Douglas Jones, March 1990
+// rewritten in C, Jan 2007
+
 func main() {
+	var input, order, diagformat string
+	var dropunused bool
+	flag.StringVar(&input, "input", input, "grammar to process")
+	flag.StringVar(&diagformat, "format", "text", "format of error messages, text, json or sarif")
+	flag.StringVar(&order, "order", "reach", "order of the rules, reach, source or alpha")
+	flag.BoolVar(&dropunused, "dropunused", dropunused, "leave out rules that can't be reached")
+	flag.Parse()
+
+	if err := gtools.SetDiagnosticFormat(diagformat); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := gtools.SetWriteOrder(order); err != nil {
+		log.Fatal(err)
+	}
+	gtools.SetDropUnused(dropunused)
+
+	if input != "" {
+		if err := gtools.SetStdin(input); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	gtools.ReadGrammar()
+	gtools.StartFollow()
+	gtools.WriteGrammar()
+	gtools.WriteDiagnostics()
 }
@@ -5,5 +5,29 @@
 // to count the rules and symbols of a BNF grammar.
 package main
 
+import (
+	"flag"
+	"github.com/mdhender/gtools"
+	"log"
+)
+
 func main() {
+	var input, diagformat string
+	flag.StringVar(&input, "input", input, "grammar to process")
+	flag.StringVar(&diagformat, "format", "text", "format of error messages, text, json or sarif")
+	flag.Parse()
+
+	if err := gtools.SetDiagnosticFormat(diagformat); err != nil {
+		log.Fatal(err)
+	}
+
+	if input != "" {
+		if err := gtools.SetStdin(input); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	gtools.ReadGrammar()
+	gtools.GramStats()
+	gtools.WriteDiagnostics()
 }
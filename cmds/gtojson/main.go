@@ -0,0 +1,43 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+// Package main implements the command line tool gtojson
+// to write a BNF or EBNF grammar as JSON or YAML.
+package main
+
+import (
+	"flag"
+	"github.com/mdhender/gtools"
+	"log"
+)
+
+func main() {
+	var input, diagformat string
+	var yaml, startfollow bool
+	flag.StringVar(&input, "input", input, "grammar to process")
+	flag.StringVar(&diagformat, "format", "text", "format of error messages, text, json or sarif")
+	flag.BoolVar(&yaml, "yaml", yaml, "write YAML instead of JSON")
+	flag.BoolVar(&startfollow, "startfollow", startfollow, "include start and follow sets")
+	flag.Parse()
+
+	if err := gtools.SetDiagnosticFormat(diagformat); err != nil {
+		log.Fatal(err)
+	}
+
+	if input != "" {
+		if err := gtools.SetStdin(input); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	gtools.ReadGrammar()
+	if startfollow {
+		gtools.StartFollow()
+	}
+	if yaml {
+		gtools.WriteYAML()
+	} else {
+		gtools.WriteJSON()
+	}
+	gtools.WriteDiagnostics()
+}
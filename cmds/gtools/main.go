@@ -0,0 +1,359 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+// Package main implements the command line tool gtools
+// to run any of the other tools, or a pipeline of them, on one grammar.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/mdhender/gtools"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const usage = `usage: gtools command[,command...] [flags] [arguments]
+The commands are run in turn on one grammar held in memory, so
+    gtools deebnf,deempty,squeeze -input x.gr
+does what gdeebnf | gdeempty | gsqueeze does, but keeps the line numbers
+of x.gr.  A reader may only come first; without one the grammar is read
+in the gtools notation.  If the last command doesn't write anything, the
+grammar is written in the gtools notation at the end.  Commands that take
+arguments take them in turn from the arguments after the flags.
+`
+
+// options holds the flags of all the commands
+type options struct {
+	input      string
+	diagformat string
+	order      string
+	dropunused bool
+	bnf        bool
+	format     gtools.Format
+	align      string
+	start      string
+	length     int
+	depth      int
+	pairs      bool
+	weights    string
+	mutants    int
+	name       string
+	style      string
+	docformat  string
+	title      string
+	dir        string
+	html       bool
+	normalise  bool
+	disable    string
+	failed     bool // diff or lint found something, exit with 1
+	args       []string
+}
+
+// a command, kind says where it may go in a pipeline
+type command struct {
+	name  string
+	kind  int
+	nargs int
+	help  string
+	run   func(o *options, args []string) error
+}
+
+const (
+	reader = iota // reads the grammar, only first
+	pass          // changes the grammar in memory
+	writer        // writes something about the grammar
+)
+
+var commands = []command{
+	{"fromg4", reader, 0, "read an ANTLR4 grammar", func(o *options, args []string) error {
+		gtools.ReadANTLR()
+		return nil
+	}},
+	{"fromgoebnf", reader, 0, "read a Go EBNF grammar, starting at -start", func(o *options, args []string) error {
+		gtools.ReadGoEBNF(o.start)
+		return nil
+	}},
+	{"fromjson", reader, 0, "read a JSON grammar", func(o *options, args []string) error {
+		gtools.ReadJSON()
+		return nil
+	}},
+	{"deebnf", pass, 0, "convert EBNF to BNF", func(o *options, args []string) error {
+		gtools.GDeEBNF()
+		return nil
+	}},
+	{"deempty", pass, 0, "eliminate empty symbols", func(o *options, args []string) error {
+		gtools.DeEmpty()
+		return nil
+	}},
+	{"squeeze", pass, 0, "eliminate redundancy", func(o *options, args []string) error {
+		gtools.Squeeze()
+		return nil
+	}},
+	{"startfollow", pass, 0, "find the start and follow sets", func(o *options, args []string) error {
+		gtools.StartFollow()
+		return nil
+	}},
+	{"shortest", pass, 0, "find the shortest string each nonterminal derives", func(o *options, args []string) error {
+		gtools.Shortest()
+		return nil
+	}},
+	{"weights", pass, 0, "set the weights sample picks rules by from the file -weights", func(o *options, args []string) error {
+		if o.weights == "" {
+			return fmt.Errorf("weights needs a file of weights, given by -weights")
+		}
+		return gtools.ReadWeights(o.weights)
+	}},
+	{"balance", pass, 0, "set the weights so sample writes strings -length tokens long on average", func(o *options, args []string) error {
+		return gtools.BalanceWeights(o.length)
+	}},
+	{"rename", pass, 2, "rename old new: give the symbol old the name new", func(o *options, args []string) error {
+		return gtools.RenameSymbol(args[0], args[1])
+	}},
+	{"inline", pass, 1, "inline symbol: replace each use of symbol with its rules", func(o *options, args []string) error {
+		return gtools.InlineSymbol(args[0])
+	}},
+	{"extract", pass, 5, "extract symbol rule from to new: put elements of an alternative under new", func(o *options, args []string) error {
+		var n [3]int
+		for i := range n {
+			var err error
+			if n[i], err = strconv.Atoi(args[i+1]); err != nil {
+				return fmt.Errorf("extract: %q is not a number", args[i+1])
+			}
+		}
+		return gtools.ExtractRule(args[0], n[0], n[1], n[2], args[4])
+	}},
+	{"copy", writer, 0, "write the grammar", func(o *options, args []string) error {
+		gtools.WriteGrammar()
+		return nil
+	}},
+	{"sample", writer, 0, "write an example string", func(o *options, args []string) error {
+		gtools.Sample()
+		return nil
+	}},
+	{"cover", writer, 0, "write strings that use every rule, up to -depth deep", func(o *options, args []string) error {
+		gtools.SampleCover(o.depth, o.pairs)
+		return nil
+	}},
+	{"mutants", writer, 0, "write -mutants strings not in the language, made by mutating samples", func(o *options, args []string) error {
+		return gtools.SampleMutants(o.mutants)
+	}},
+	{"enumerate", writer, 0, "list the strings of up to -length tokens or -depth deep", func(o *options, args []string) error {
+		return gtools.Enumerate(o.start, o.length, o.depth)
+	}},
+	{"listweights", writer, 0, "write the weights of the rules, as -weights reads them", func(o *options, args []string) error {
+		gtools.WriteWeights()
+		return nil
+	}},
+	{"derivations", writer, 0, "list the shortest string of each nonterminal and its derivation", func(o *options, args []string) error {
+		gtools.WriteDerivations()
+		return nil
+	}},
+	{"stats", writer, 0, "count the rules and symbols", func(o *options, args []string) error {
+		gtools.GramStats()
+		return nil
+	}},
+	{"tog4", writer, 0, "write an ANTLR4 grammar named -name", func(o *options, args []string) error {
+		gtools.WriteANTLR(o.name)
+		return nil
+	}},
+	{"togoebnf", writer, 0, "write a Go EBNF grammar", func(o *options, args []string) error {
+		gtools.WriteGoEBNF()
+		return nil
+	}},
+	{"topeg", writer, 0, "write a PEG in the -style syntax", func(o *options, args []string) error {
+		if o.style != "pigeon" && o.style != "peg" {
+			return fmt.Errorf("unknown style %q", o.style)
+		}
+		gtools.WritePEG(o.style)
+		return nil
+	}},
+	{"tojson", writer, 0, "write the grammar as JSON", func(o *options, args []string) error {
+		gtools.WriteJSON()
+		return nil
+	}},
+	{"toyaml", writer, 0, "write the grammar as YAML", func(o *options, args []string) error {
+		gtools.WriteYAML()
+		return nil
+	}},
+	{"todot", writer, 0, "write the symbol dependency graph for Graphviz", func(o *options, args []string) error {
+		gtools.WriteDOT()
+		return nil
+	}},
+	{"railroad", writer, 0, "draw railroad diagrams, SVG files in -dir or with -html a page", func(o *options, args []string) error {
+		if o.html {
+			gtools.WriteRailroadHTML(o.title)
+			return nil
+		}
+		return gtools.WriteRailroadSVG(o.dir)
+	}},
+	{"lint", writer, 0, "check for likely mistakes, leaving out the -disable checks", func(o *options, args []string) error {
+		var ids []string
+		if o.disable != "" {
+			ids = strings.Split(o.disable, ",")
+		}
+		found, err := gtools.Lint(ids)
+		if err != nil {
+			return err
+		}
+		o.failed = o.failed || len(found) != 0
+		return gtools.FormatDiagnostics(os.Stdout, o.diagformat, found)
+	}},
+	{"diff", writer, 1, "diff new.gr: report how the grammar in new.gr differs, -normalise for the rules only", func(o *options, args []string) error {
+		if o.normalise {
+			gtools.GDeEBNF()
+			gtools.Squeeze()
+		}
+		gtools.RememberGrammar()
+		if err := gtools.SetStdin(args[0]); err != nil {
+			return err
+		}
+		gtools.ReadGrammar()
+		if o.normalise {
+			gtools.GDeEBNF()
+			gtools.Squeeze()
+		}
+		o.failed = gtools.DiffGrammar() || o.failed
+		return nil
+	}},
+	{"docs", writer, 0, "write documentation in the -docformat format", func(o *options, args []string) error {
+		if o.docformat != "markdown" && o.docformat != "html" {
+			return fmt.Errorf("unknown format %q", o.docformat)
+		}
+		gtools.WriteDocs(o.docformat, o.title)
+		return nil
+	}},
+}
+
+// lookup finds the command called name
+func lookup(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+func main() {
+	var o options
+	var pipeline []*command
+	var args []string
+
+	o.format = gtools.DefaultFormat()
+	fs := flag.NewFlagSet("gtools", flag.ExitOnError)
+	fs.StringVar(&o.input, "input", o.input, "grammar to process")
+	fs.StringVar(&o.diagformat, "format", "text", "format of error messages, text, json or sarif")
+	fs.StringVar(&o.order, "order", "reach", "order of the rules, reach, source or alpha")
+	fs.BoolVar(&o.dropunused, "dropunused", o.dropunused, "leave out rules that can't be reached")
+	fs.BoolVar(&o.bnf, "bnf", o.bnf, "treat ( ) [ ] { } as terminals, not EBNF brackets")
+	fs.IntVar(&o.format.Width, "width", o.format.Width, "wrap lines before this column, 0 for no wrapping")
+	fs.StringVar(&o.format.RuleSym, "rulesym", o.format.RuleSym, "rule symbol, ::= = : or :=")
+	fs.StringVar(&o.align, "align", "rule", "put the bars under the rule symbol (rule) or at -indent (indent)")
+	fs.IntVar(&o.format.Indent, "indent", o.format.Indent, "column of the bars with -align indent")
+	fs.BoolVar(&o.format.OneAlt, "onealt", o.format.OneAlt, "put each alternative on a line of its own")
+	fs.BoolVar(&o.format.Period, "period", o.format.Period, "end each rule with a period, as Wirth did")
+	fs.StringVar(&o.start, "start", o.start, "distinguished symbol for fromgoebnf (default first production), or the symbol for enumerate")
+	fs.IntVar(&o.length, "length", o.length, "enumerate the strings of up to this many tokens, 0 for no limit, or balance to this many on average")
+	fs.IntVar(&o.depth, "depth", o.depth, "enumerate or cover with derivations up to this deep, 0 for no limit")
+	fs.BoolVar(&o.pairs, "pairs", o.pairs, "cover every rule for each symbol of every rule too")
+	fs.StringVar(&o.weights, "weights", o.weights, "file of weights for the weights command")
+	fs.IntVar(&o.mutants, "mutants", 10, "number of strings for the mutants command")
+	fs.StringVar(&o.name, "name", "Grammar", "name of the ANTLR4 grammar for tog4")
+	fs.StringVar(&o.style, "style", "pigeon", "PEG syntax for topeg, pigeon or peg")
+	fs.StringVar(&o.docformat, "docformat", "markdown", "format for docs, markdown or html")
+	fs.StringVar(&o.title, "title", "Grammar", "title for docs and railroad -html")
+	fs.StringVar(&o.dir, "dir", ".", "directory for the railroad SVG files")
+	fs.BoolVar(&o.html, "html", o.html, "railroad writes one HTML page to stdout")
+	fs.StringVar(&o.disable, "disable", o.disable, "lint checks to leave out, separated by commas")
+	fs.BoolVar(&o.normalise, "normalise", o.normalise, "diff after deebnf and squeeze, so notation doesn't matter")
+	fs.Usage = func() {
+		_, _ = os.Stderr.WriteString(usage + "commands:\n")
+		for _, c := range commands {
+			fmt.Fprintf(os.Stderr, "  %-12s %s\n", c.name, c.help)
+		}
+		_, _ = os.Stderr.WriteString("flags:\n")
+		fs.PrintDefaults()
+	}
+
+	// the commands come first, but allow the flags first too
+	args = os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		_ = fs.Parse(args[1:])
+		args = append([]string{args[0]}, fs.Args()...)
+	} else {
+		_ = fs.Parse(args)
+		args = fs.Args()
+	}
+	if len(args) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	for i, name := range strings.Split(args[0], ",") {
+		c := lookup(name)
+		if c == nil {
+			log.Fatalf("unknown command %q", name)
+		} else if c.kind == reader && i != 0 {
+			log.Fatalf("%s must be the first command", name)
+		}
+		pipeline = append(pipeline, c)
+	}
+	o.args = args[1:]
+	need := 0
+	for _, c := range pipeline {
+		need += c.nargs
+	}
+	if len(o.args) != need {
+		log.Fatalf("%s takes %d arguments, not %d", args[0], need, len(o.args))
+	}
+
+	if err := gtools.SetDiagnosticFormat(o.diagformat); err != nil {
+		log.Fatal(err)
+	}
+	if err := gtools.SetWriteOrder(o.order); err != nil {
+		log.Fatal(err)
+	}
+	gtools.SetDropUnused(o.dropunused)
+	switch o.align {
+	case "rule":
+		o.format.Align = gtools.ALIGNRULE
+	case "indent":
+		o.format.Align = gtools.ALIGNINDENT
+	default:
+		log.Fatalf("unknown alignment %q", o.align)
+	}
+	if err := gtools.SetFormat(o.format); err != nil {
+		log.Fatal(err)
+	}
+	gtools.SetPlainBNF(o.bnf)
+
+	if o.input != "" {
+		if err := gtools.SetStdin(o.input); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if pipeline[0].kind != reader {
+		gtools.ReadGrammar()
+	}
+	for i, c := range pipeline {
+		if err := c.run(&o, o.args[:c.nargs]); err != nil {
+			gtools.WriteDiagnostics()
+			log.Fatalf("%s: %v", c.name, err)
+		}
+		o.args = o.args[c.nargs:]
+		if i < len(pipeline)-1 && c.kind != writer {
+			gtools.ReadBack() // as the next tool of a pipe would read it
+		}
+	}
+	if pipeline[len(pipeline)-1].kind != writer {
+		gtools.WriteGrammar()
+	}
+	gtools.WriteDiagnostics()
+	if o.failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,339 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"strings"
+)
+
+// Output a few strings that between them use every rule.
+// Where sample picks rules at random, this goes over the rules of the
+// symbols that can be reached from the distinguished symbol and, for
+// each rule no string so far has used, writes a string that uses it,
+// reached from the distinguished symbol by the shallowest path there is
+// and filled out with rules no string has used yet where they fit, or
+// else with the rules of the shallowest derivations.  Optionally, it does
+// the same for each pair of rules, one used for a symbol of the other.
+// A rule that can't be used within the depth derivations are limited to
+// is reported, with its line, as an error would be.
+
+// global variables private to this package
+var _cover struct {
+	depth   int                     // the depth derivations are limited to, 0 for none
+	pairs   bool                    // cover pairs of rules too
+	height  map[PSYMBOL]int         // the depth of the shallowest derivation, -1 for none
+	least   map[PSYMBOL]PPRODUCTION // the rule of the shallowest derivation
+	parent  map[PSYMBOL]coverstep   // how the shallowest path to a symbol gets there
+	owner   map[PPRODUCTION]PSYMBOL // the symbol each rule is a rule of
+	used    map[PPRODUCTION]bool    // the rules used by the strings so far
+	paired  map[coverpair]bool      // the pairs of rules used by the strings so far
+	written map[string]bool         // the strings written so far
+	tree    struct {                // the derivation being built
+		tokens  []string             // its terminals
+		deepest int                  // the depth of its deepest nonterminal
+		failed  bool                 // it needed a symbol with no derivation
+		used    []PPRODUCTION        // the rules it uses
+		pairs   []coverpair          // and the pairs of them
+		inuse   map[PPRODUCTION]bool // the same rules, to look them up
+	}
+}
+
+// the rule used for a symbol, and the symbol of it a path goes on with,
+// -1 for none
+type coverstep struct {
+	p  PPRODUCTION
+	at int
+}
+
+// rule q used for the symbol at of rule p
+type coverpair struct {
+	p  PPRODUCTION
+	at int
+	q  PPRODUCTION
+}
+
+// SampleCover writes strings that use every rule of the symbols that can
+// be reached, and every pair of a rule and a rule for one of its symbols
+// if pairs is set, by derivations up to depth levels deep, 0 for no limit
+func SampleCover(depth int, pairs bool) {
+	samplecover(depth, pairs)
+}
+
+// coverheight is the depth of the shallowest derivation that uses p, or
+// -1 if it has none
+func coverheight(p PPRODUCTION) int {
+	var e PELEMENT
+	var h int
+
+	h = 1
+	for e = p.data; e != nil; e = e.next {
+		if NONTERMINAL(e.data) {
+			if _cover.height[e.data] < 0 {
+				return -1
+			} else if _cover.height[e.data]+1 > h {
+				h = _cover.height[e.data] + 1
+			}
+		}
+	}
+	return h
+}
+
+// coversetup finds the shallowest derivation of each nonterminal, and the
+// shallowest path to it from the distinguished symbol
+func coversetup(order []PSYMBOL) {
+	var s PSYMBOL
+	var p PPRODUCTION
+	var e PELEMENT
+	var changed bool
+	var queue []PSYMBOL
+	var at int
+
+	_cover.height = map[PSYMBOL]int{}
+	_cover.least = map[PSYMBOL]PPRODUCTION{}
+	for _, s = range order {
+		_cover.height[s] = -1
+	}
+	for changed = true; changed; {
+		changed = false
+		for _, s = range order {
+			for p = s.data; p != nil; p = p.next {
+				h := coverheight(p)
+				if h > 0 && (_cover.height[s] < 0 || h < _cover.height[s]) {
+					_cover.height[s] = h
+					_cover.least[s] = p
+					changed = true
+				}
+			}
+		}
+	}
+
+	// breadth first, so the first path found to a symbol is a shallowest
+	_cover.parent = map[PSYMBOL]coverstep{head: {nil, -1}}
+	queue = []PSYMBOL{head}
+	for len(queue) > 0 {
+		s = queue[0]
+		queue = queue[1:]
+		for p = s.data; p != nil; p = p.next {
+			if coverheight(p) < 0 { // a path can't go through it
+				continue
+			}
+			at = 0
+			for e = p.data; e != nil; e = e.next {
+				if _, ok := _cover.parent[e.data]; !ok && NONTERMINAL(e.data) {
+					_cover.parent[e.data] = coverstep{p, at}
+					queue = append(queue, e.data)
+				}
+				at = at + 1
+			}
+		}
+	}
+}
+
+// coverpath is the steps of the path from the distinguished symbol to s
+func coverpath(s PSYMBOL) []coverstep {
+	var path []coverstep
+	var step coverstep
+
+	for s != head {
+		step = _cover.parent[s]
+		path = append([]coverstep{step}, path...)
+		s = _cover.owner[step.p]
+	}
+	return path
+}
+
+// coverpick picks the rule to use for s, at level from the top, used for
+// symbol at of rule p: one no string has used if it fits, in the pairs
+// mode one no string has used after p, or the rule of the shallowest
+// derivation of s
+func coverpick(s PSYMBOL, level int, p PPRODUCTION, at int) PPRODUCTION {
+	var q PPRODUCTION
+	var fits func(q PPRODUCTION) bool
+
+	fits = func(q PPRODUCTION) bool {
+		h := coverheight(q)
+		return h > 0 && (_cover.depth == 0 || level-1+h <= _cover.depth)
+	}
+	for q = s.data; q != nil; q = q.next {
+		if !_cover.used[q] && !_cover.tree.inuse[q] && fits(q) {
+			return q
+		}
+	}
+	if _cover.pairs && p != nil {
+		for q = s.data; q != nil; q = q.next {
+			if !_cover.paired[coverpair{p, at, q}] && !_cover.tree.inuse[q] && fits(q) {
+				return q
+			}
+		}
+	}
+	return _cover.least[s]
+}
+
+// coverexpand adds the derivation of s at level from the top, used for
+// symbol at of rule p, to the tree, following the plan if there is one
+func coverexpand(s PSYMBOL, level int, p PPRODUCTION, at int, plan []coverstep) {
+	var q PPRODUCTION
+	var e PELEMENT
+	var next, j int
+
+	if TERMINAL(s) {
+		if s != emptypt {
+			_cover.tree.tokens = append(_cover.tree.tokens, symname(s))
+		}
+		return
+	}
+	next = -1
+	if len(plan) > 0 {
+		q = plan[0].p
+		next = plan[0].at
+		plan = plan[1:]
+	} else {
+		q = coverpick(s, level, p, at)
+	}
+	if q == nil { // no derivation at all
+		_cover.tree.failed = true
+		return
+	}
+
+	if level > _cover.tree.deepest {
+		_cover.tree.deepest = level
+	}
+	_cover.tree.used = append(_cover.tree.used, q)
+	_cover.tree.inuse[q] = true
+	if p != nil {
+		_cover.tree.pairs = append(_cover.tree.pairs, coverpair{p, at, q})
+	}
+	j = 0
+	for e = q.data; e != nil; e = e.next {
+		if j == next {
+			coverexpand(e.data, level+1, q, j, plan)
+		} else {
+			coverexpand(e.data, level+1, q, j, nil)
+		}
+		j = j + 1
+	}
+}
+
+// covertry builds the derivation the plan calls for, keeping it and
+// writing its string if it is within the depth, and reports whether it was
+func covertry(plan []coverstep) bool {
+	var str string
+
+	_cover.tree.tokens = nil
+	_cover.tree.deepest = 0
+	_cover.tree.failed = false
+	_cover.tree.used = nil
+	_cover.tree.pairs = nil
+	_cover.tree.inuse = map[PPRODUCTION]bool{}
+	coverexpand(head, 1, nil, -1, plan)
+	if _cover.tree.failed || (_cover.depth > 0 && _cover.tree.deepest > _cover.depth) {
+		return false
+	}
+
+	for _, p := range _cover.tree.used {
+		_cover.used[p] = true
+	}
+	for _, pair := range _cover.tree.pairs {
+		_cover.paired[pair] = true
+	}
+	str = strings.Join(_cover.tree.tokens, " ")
+	if !_cover.written[str] {
+		_cover.written[str] = true
+		fputs(str+"\n", stdout)
+	}
+	return true
+}
+
+// covertext is rule p as it would be written, for reporting it
+func covertext(p PPRODUCTION) string {
+	var text string
+	var e PELEMENT
+
+	text = symname(_cover.owner[p]) + " ::="
+	for e = p.data; e != nil; e = e.next {
+		text = text + " " + symname(e.data)
+	}
+	return text
+}
+
+// coverspan is where p is, or at least the line of it
+func coverspan(p PPRODUCTION) span {
+	if p.pos.line == 0 {
+		return span{file: srcfile, line: p.line}
+	}
+	return p.pos
+}
+
+// void samplecover( int depth, bool pairs )
+// write strings that use every rule
+func samplecover(depth int, pairs bool) {
+	var order, reached []PSYMBOL
+	var p, q PPRODUCTION
+	var e PELEMENT
+	var within string
+	var at int
+
+	if head == nil || TERMINAL(head) {
+		return
+	}
+	_cover.depth = depth
+	if depth < 0 {
+		_cover.depth = 0
+	}
+	_cover.pairs = pairs
+	_cover.used = map[PPRODUCTION]bool{}
+	_cover.paired = map[coverpair]bool{}
+	_cover.written = map[string]bool{}
+	within = ""
+	if _cover.depth > 0 {
+		within = sprintf(" WITHIN DEPTH %d", _cover.depth)
+	}
+
+	// the rules of the reachable symbols, in the order writeg puts them
+	order = reachorder()
+	_cover.owner = map[PPRODUCTION]PSYMBOL{}
+	for _, s := range order {
+		if s.state == TOUCHED {
+			reached = append(reached, s)
+		}
+		for p = s.data; p != nil; p = p.next {
+			_cover.owner[p] = s
+		}
+	}
+	coversetup(reached)
+
+	for _, s := range reached {
+		if _, ok := _cover.parent[s]; !ok { // there is no way to get to s
+			for p = s.data; p != nil; p = p.next {
+				errorat("NOT COVERED"+within+": "+covertext(p), coverspan(p))
+			}
+			continue
+		}
+		for p = s.data; p != nil; p = p.next {
+			if !_cover.used[p] && !covertry(append(coverpath(s), coverstep{p, -1})) {
+				errorat("NOT COVERED"+within+": "+covertext(p), coverspan(p))
+			}
+		}
+	}
+	if !pairs {
+		return
+	}
+	for _, s := range reached {
+		if _, ok := _cover.parent[s]; !ok {
+			continue
+		}
+		for p = s.data; p != nil; p = p.next {
+			at = 0
+			for e = p.data; e != nil; e = e.next {
+				for q = e.data.data; q != nil && NONTERMINAL(e.data); q = q.next {
+					if !_cover.paired[coverpair{p, at, q}] &&
+						!covertry(append(coverpath(s), coverstep{p, at}, coverstep{q, -1})) {
+						errorat(sprintf("PAIR NOT COVERED%s: %s, THEN %s ON LINE %d", within, covertext(p), covertext(q), coverspan(q).line), coverspan(p))
+					}
+				}
+				at = at + 1
+			}
+		}
+	}
+}
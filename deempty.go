@@ -146,12 +146,16 @@ func dupminus(s PSYMBOL, p PPRODUCTION, e PELEMENT) {
 	var sp PPRODUCTION /* a successor of p used for duplicate elimination */
 
 	np = NEWPRODUCTION()
+	np.line = p.line
+	np.from = origins(p)
 	pne = &(np.data)
 
 	for pe = p.data; pe != nil; pe = pe.next {
 		if pe != e {
 			/* for all elements of p except e */
 			ne = NEWELEMENT()
+			ne.line = pe.line
+			ne.pos = pe.pos
 			ne.data = pe.data
 			*pne = ne
 			pne = &(ne.next)
@@ -160,12 +164,14 @@ func dupminus(s PSYMBOL, p PPRODUCTION, e PELEMENT) {
 
 	/* mark end of new rule */
 	*pne = nil
+	np.pos = elemspan(np.data)
 
 	/* avoid inserting duplicates of existing rules */
 	sp = s.data
 	// do {...} while (sp != nil)
 	for firstTime := true; firstTime || sp != nil; firstTime = false {
 		if samerule(np, sp) {
+			addorigins(sp, np)
 			np.data = nil
 			sp = nil
 		} else {
@@ -201,6 +207,17 @@ func cleanempty(s PSYMBOL) {
 			/* delete this production rule instead of moving on */
 			*pp = p.next
 
+			/* its comments go with a rule that is left */
+			if p.next != nil {
+				p.next.comment = p.comment + p.next.comment
+			} else if s.data != nil {
+				q := s.data
+				for q.next != nil {
+					q = q.next
+				}
+				q.comment = q.comment + p.comment
+			}
+
 		} else { /* CANBEEMPTY = NONEMPTY */
 			/* clean up the rule, possibly adding new productions */
 
@@ -232,20 +249,12 @@ func cleanempty(s PSYMBOL) {
 	}
 }
 
-// The interface
-
-// void deempty()
-// eliminate references to the empty symbol
-func deempty() {
-	// handles used in list traversals
+// markempty marks each symbol and rule ISEMPTY, CANBEEMPTY or NONEMPTY,
+// as deempty and anything else that needs to know what can be empty use it
+func markempty() {
 	var s PSYMBOL
 	var p PPRODUCTION
 
-	if emptypt == nil {
-		errormsg("EMPTY SYMBOL MUST BE DEFINED", -1)
-		return /* quit if no analysis possible */
-	}
-
 	/* apply initial markings on all symbols */
 	for s = symlist; s != nil; s = s.next {
 		if TERMINAL(s) {
@@ -260,7 +269,9 @@ func deempty() {
 		}
 	}
 	/* the distinguished empty symbol is the exceptional terminal */
-	emptypt.state = ISEMPTY
+	if emptypt != nil {
+		emptypt.state = ISEMPTY
+	}
 
 	// do {...} while (change)
 	for firstTime := true; firstTime || _deempty.change; firstTime = false { /* keep trying until no change is made to the grammar */
@@ -274,6 +285,23 @@ func deempty() {
 			}
 		}
 	}
+}
+
+// The interface
+
+// void deempty()
+// eliminate references to the empty symbol
+func deempty() {
+	// handles used in list traversals
+	var s PSYMBOL
+	var p PPRODUCTION
+
+	if emptypt == nil {
+		errormsg("EMPTY SYMBOL MUST BE DEFINED", -1)
+		return /* quit if no analysis possible */
+	}
+
+	markempty()
 
 	/* now use the markup to rewrite rules accounting for emptyness */
 	for s = symlist; s != nil; s = s.next {
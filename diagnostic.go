@@ -0,0 +1,387 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// Diagnostics.
+// The tools report errors in a grammar on stderr as they find them, in
+// the form >>MESSAGE on line N<<, or >>MESSAGE on line N of FILE<< when
+// the grammar came from a file, so that an error in an included file
+// names the file it is in, or >>MESSAGE in FILE<< for one with no line;
+// the problems glint finds are written the same way, with the check
+// that found them, as >>MESSAGE [check] on line N of FILE<<.  A program that shows them some other way,
+// such as an editor, can have them as Diagnostics instead, and the tools
+// can write them all at the end as JSON or SARIF for CI to read, each
+// with a rule ID made from its message,
+//    |
+//    | >>MISSING CLOSING QUOTE on line 3<<    MISSING_CLOSING_QUOTE
+//    | >>UNEXPECTED ) on line 7<<             UNEXPECTED_RPAREN
+//    | >>UNEXPECTED foo on line 9<<           UNEXPECTED_TOKEN
+//    |
+// so the variable part of a message, a symbol or a file, is left out.
+
+// Diagnostic is an error found in a grammar, or a problem found by one of
+// the checks of Lint.  Line is 0 if it can't be given a line, and Col is
+// 0 if only the line is known; columns count characters from 1, and the
+// end is just past what is wrong.
+type Diagnostic struct {
+	Message  string
+	File     string // "" for standard input
+	Line     int
+	Col      int
+	EndLine  int
+	EndCol   int
+	Severity string // error, warning or note
+	Check    string // the check of Lint that found it, "" for an error
+}
+
+// global variables private to this package
+var (
+	diagnose  func(Diagnostic) // where errors go, nil for stderr
+	diagfmt   string           // format for WriteDiagnostics, "" for text
+	diagnosed []Diagnostic     // errors kept for WriteDiagnostics
+)
+
+// messages that have a variable part, and the rule IDs and severities
+// they get, in the order they are tried
+var diagrules = []struct {
+	prefix   string
+	rule     string
+	severity string
+}{
+	{"FILE INCLUDES ITSELF", "FILE_INCLUDES_ITSELF", "error"},
+	{"CANNOT INCLUDE", "CANNOT_INCLUDE", "error"},
+	{"UNKNOWN DIRECTIVE", "UNKNOWN_DIRECTIVE", "error"},
+	{"UNKNOWN SCHEMA", "UNKNOWN_SCHEMA", "error"},
+	{"UNDECLARED SYMBOL", "UNDECLARED_SYMBOL", "error"},
+	{"BAD SYMBOL NAME", "BAD_SYMBOL_NAME", "error"},
+	{"BAD COMMENT", "BAD_COMMENT", "error"},
+	{"SYMBOL LISTED TWICE", "SYMBOL_LISTED_TWICE", "error"},
+	{"SYMBOL TOO LONG", "SYMBOL_TOO_LONG", "error"},
+	{"NO PRODUCTION NAMED", "NO_PRODUCTION_NAMED", "error"},
+	{"EMPTY LITERAL", "EMPTY_LITERAL_USED_AS_TOKEN", "error"},
+	{"BAD TOKEN", "BAD_TOKEN", "error"},
+	{"BAD JSON", "BAD_JSON", "error"},
+	{"MISSING ::=", "MISSING_RULE_SYMBOL", "error"},
+	{"MISSING CLOSING >", "MISSING_CLOSING_ANGLE", "error"},
+	{"DISTINGUISHED SYMBOL FIRST GIVEN", "DISTINGUISHED_SYMBOL_FIRST_GIVEN", "note"},
+	{"EMPTY SYMBOL FIRST GIVEN", "EMPTY_SYMBOL_FIRST_GIVEN", "note"},
+	{"WEIGHT FIRST GIVEN", "WEIGHT_FIRST_GIVEN", "note"},
+	{"PEG: ALTERNATIVE OF", "PEG_ALTERNATIVE_NEVER_TRIED", "warning"},
+	{"PEG: GREEDY", "PEG_GREEDY_REPETITION", "warning"},
+	{"PEG:", "PEG_LEFT_RECURSIVE", "warning"},
+	{"SYMBOL ", "SYMBOL_WRONG_KIND", "error"},
+	{"NOT COVERED", "NOT_COVERED", "warning"},
+	{"PAIR NOT COVERED", "PAIR_NOT_COVERED", "warning"},
+	{"DERIVES NO STRING", "DERIVES_NO_STRING", "error"},
+}
+
+// the names of the punctuation that appears in messages
+var diagpunct = map[string]string{
+	"(": "LPAREN", ")": "RPAREN",
+	"[": "LBRACKET", "]": "RBRACKET",
+	"{": "LBRACE", "}": "RBRACE",
+	"<": "LT", ">": "GT",
+	"|": "BAR", ";": "SEMICOLON",
+	":": "COLON", ".": "PERIOD",
+	"=": "EQUALS", "::=": "RULESYM",
+}
+
+// SetDiagnosticFormat sets how errors are reported, text to have them on
+// stderr as they are found, or json or sarif to keep them for
+// WriteDiagnostics
+func SetDiagnosticFormat(format string) error {
+	switch format {
+	case "text":
+		diagfmt = ""
+		diagnose = nil
+	case "json", "sarif":
+		diagfmt = format
+		diagnosed = nil
+		diagnose = func(d Diagnostic) {
+			diagnosed = append(diagnosed, d)
+		}
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+	return nil
+}
+
+// WriteDiagnostics writes the errors kept since SetDiagnosticFormat on
+// stderr, in the format it was given; with text they are already written
+func WriteDiagnostics() {
+	if diagfmt == "" {
+		return
+	}
+	if err := FormatDiagnostics(stderr, diagfmt, diagnosed); err != nil {
+		fprintf(stderr, "%v\n", err)
+	}
+	diagnosed = nil
+}
+
+// Report reports d as the errors found in a grammar are, for a tool
+// that finds a problem of its own
+func Report(d Diagnostic) {
+	if diagnose != nil {
+		diagnose(d)
+		return
+	}
+	fputs(" "+d.String()+"\n", stderr)
+}
+
+// SetDiagnostics sends the errors found from now on to f instead of
+// stderr, or to stderr again if f is nil
+func SetDiagnostics(f func(Diagnostic)) {
+	diagnose = f
+}
+
+// diagnostic is the Diagnostic for msg at sp
+func diagnostic(msg string, sp span) Diagnostic {
+	var severity string
+
+	severity = "error"
+	for _, r := range diagrules {
+		if strings.HasPrefix(msg, r.prefix) {
+			severity = r.severity
+			break
+		}
+	}
+	return Diagnostic{
+		Message:  msg,
+		File:     sp.file,
+		Line:     sp.line,
+		Col:      sp.col,
+		EndLine:  sp.endline,
+		EndCol:   sp.endcol,
+		Severity: severity,
+	}
+}
+
+// diagrule is the rule ID of d, the check of Lint that found it or one
+// made from the words of the message
+func diagrule(d Diagnostic) string {
+	var words []string
+
+	if d.Check != "" {
+		return d.Check
+	}
+	for _, r := range diagrules {
+		if strings.HasPrefix(d.Message, r.prefix) {
+			return r.rule
+		}
+	}
+	for _, w := range strings.Fields(d.Message) {
+		if name, ok := diagpunct[w]; ok {
+			words = append(words, name)
+		} else if strings.Trim(w, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") == "" &&
+			!(len(words) == 1 && words[0] == "UNEXPECTED") {
+			words = append(words, w)
+		} else { // a symbol or some such, which ends the ID
+			words = append(words, "TOKEN")
+			break
+		}
+	}
+	if len(words) == 0 {
+		return "ERROR"
+	}
+	return strings.Join(words, "_")
+}
+
+// FormatDiagnostics writes diags on w as text, one to a line, as a JSON
+// array, or as a SARIF log
+func FormatDiagnostics(w io.Writer, format string, diags []Diagnostic) error {
+	var enc *json.Encoder
+
+	enc = json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	switch format {
+	case "text":
+		for _, d := range diags {
+			if _, err := fmt.Fprintln(w, " "+d.String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "json":
+		return enc.Encode(diagjson(diags))
+	case "sarif":
+		return enc.Encode(diagsarif(diags))
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// a Diagnostic as JSON
+type jsondiagnostic struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Col      int    `json:"col,omitempty"`
+	EndLine  int    `json:"endline,omitempty"`
+	EndCol   int    `json:"endcol,omitempty"`
+}
+
+// diagjson converts diags for writing as JSON
+func diagjson(diags []Diagnostic) []jsondiagnostic {
+	var list = []jsondiagnostic{}
+
+	for _, d := range diags {
+		list = append(list, jsondiagnostic{
+			Rule:     diagrule(d),
+			Severity: d.Severity,
+			Message:  d.Message,
+			File:     d.File,
+			Line:     d.Line,
+			Col:      d.Col,
+			EndLine:  d.EndLine,
+			EndCol:   d.EndCol,
+		})
+	}
+	return list
+}
+
+// the parts of SARIF 2.1.0 the tools write
+type sariflog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifrun `json:"runs"`
+}
+
+type sarifrun struct {
+	Tool       sariftool     `json:"tool"`
+	ColumnKind string        `json:"columnKind"`
+	Results    []sarifresult `json:"results"`
+}
+
+type sariftool struct {
+	Driver sarifdriver `json:"driver"`
+}
+
+type sarifdriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifrule `json:"rules"`
+}
+
+type sarifrule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifmessage `json:"shortDescription"`
+}
+
+type sarifmessage struct {
+	Text string `json:"text"`
+}
+
+type sarifresult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifmessage    `json:"message"`
+	Locations []sariflocation `json:"locations,omitempty"`
+}
+
+type sariflocation struct {
+	PhysicalLocation sarifphysical `json:"physicalLocation"`
+}
+
+type sarifphysical struct {
+	ArtifactLocation sarifartifact `json:"artifactLocation"`
+	Region           *sarifregion  `json:"region,omitempty"`
+}
+
+type sarifartifact struct {
+	URI string `json:"uri"`
+}
+
+type sarifregion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+// diagsarif converts diags to a SARIF log, with a rule for each rule ID,
+// described by the first message with it; results with no file have no
+// location, since SARIF can't name standard input
+func diagsarif(diags []Diagnostic) sariflog {
+	var run sarifrun
+	var index = map[string]int{}
+
+	run.Tool.Driver = sarifdriver{
+		Name:           "gtools",
+		InformationURI: "https://github.com/mdhender/gtools",
+		Rules:          []sarifrule{},
+	}
+	run.ColumnKind = "unicodeCodePoints"
+	run.Results = []sarifresult{}
+	for _, d := range diags {
+		rule := diagrule(d)
+		if _, ok := index[rule]; !ok {
+			index[rule] = len(run.Tool.Driver.Rules)
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifrule{
+				ID:               rule,
+				ShortDescription: sarifmessage{Text: strings.ReplaceAll(strings.ToLower(rule), "_", " ")},
+			})
+		}
+		result := sarifresult{
+			RuleID:    rule,
+			RuleIndex: index[rule],
+			Level:     d.Severity,
+			Message:   sarifmessage{Text: d.Message},
+		}
+		if d.File != "" {
+			loc := sariflocation{PhysicalLocation: sarifphysical{ArtifactLocation: sarifartifact{URI: sarifuri(d.File)}}}
+			if d.Line > 0 {
+				loc.PhysicalLocation.Region = &sarifregion{StartLine: d.Line}
+				if d.Col > 0 {
+					loc.PhysicalLocation.Region.StartColumn = d.Col
+					if d.EndLine > 0 && d.EndCol > 0 {
+						loc.PhysicalLocation.Region.EndLine = d.EndLine
+						loc.PhysicalLocation.Region.EndColumn = d.EndCol
+					}
+				}
+			}
+			result.Locations = []sariflocation{loc}
+		}
+		run.Results = append(run.Results, result)
+	}
+	return sariflog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifrun{run},
+	}
+}
+
+// sarifuri is file as a relative URI reference, as CI wants it
+func sarifuri(file string) string {
+	var parts []string
+
+	parts = strings.Split(filepath.ToSlash(file), "/")
+	for i := range parts {
+		parts[i] = url.PathEscape(parts[i])
+	}
+	return strings.Join(parts, "/")
+}
+
+// String gives d as the tools write errors, >>message [check] on line N
+// of FILE<<, leaving out what isn't known
+func (d Diagnostic) String() string {
+	var msg string
+
+	msg = d.Message
+	if d.Check != "" {
+		msg = msg + " [" + d.Check + "]"
+	}
+	return errortext(msg, d.Line, d.File)
+}
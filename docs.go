@@ -0,0 +1,260 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"sort"
+	"strings"
+)
+
+// Grammar documentation.
+// writedocs writes the global grammar structure as a cross linked
+// document, in HTML or in Markdown.  The nonterminals come in the order
+// writeg puts them out, each with an anchor, its description from the
+// comments in the source, its rules with every symbol linked to where it
+// is documented, and a list of the symbols whose rules use it.  The
+// terminals are listed alphabetically in a glossary at the end, each with
+// the symbols that use it.
+
+func WriteDocs(format string, title string) {
+	writedocs(format, title)
+}
+
+// global variables private to this package
+var _docs struct {
+	html    bool                  // HTML, else Markdown
+	anchors map[PSYMBOL]string    // anchor of each documented symbol
+	usedby  map[PSYMBOL][]PSYMBOL // the nonterminals using each symbol
+}
+
+// decide on the anchor for each symbol and who uses it
+func docsetup(order []PSYMBOL, terminals []PSYMBOL) {
+	var used map[string]bool
+	var seen map[PSYMBOL]map[PSYMBOL]bool
+	var p PPRODUCTION
+	var e PELEMENT
+	var id string
+
+	used = map[string]bool{}
+	_docs.anchors = map[PSYMBOL]string{}
+	for _, s := range order {
+		id = identifier(symname(s))
+		if id == "" {
+			id = "rule"
+		}
+		_docs.anchors[s] = uniquename(id, used)
+	}
+	for _, s := range terminals {
+		id = identifier(symname(s))
+		if id == "" {
+			name := symname(s)
+			if isquoted(name) && len(name) > 2 {
+				name = name[1 : len(name)-1]
+			}
+			id = strings.ToLower(punctname(name))
+		}
+		_docs.anchors[s] = uniquename("t-"+id, used)
+	}
+
+	_docs.usedby = map[PSYMBOL][]PSYMBOL{}
+	seen = map[PSYMBOL]map[PSYMBOL]bool{}
+	for _, s := range order {
+		for p = s.data; p != nil; p = p.next {
+			for e = p.data; e != nil; e = e.next {
+				if e.data == s {
+					continue
+				}
+				if seen[e.data] == nil {
+					seen[e.data] = map[PSYMBOL]bool{}
+				}
+				if !seen[e.data][s] {
+					seen[e.data][s] = true
+					_docs.usedby[e.data] = append(_docs.usedby[e.data], s)
+				}
+			}
+		}
+	}
+}
+
+// the terminals to put in the glossary, in alphabetical order
+func docterminals() []PSYMBOL {
+	var s PSYMBOL
+	var list []PSYMBOL
+
+	for s = symlist; s != nil; s = s.next {
+		if _, _, ok := metakind(s); TERMINAL(s) && s != emptypt && !ok {
+			list = append(list, s)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return symname(list[i]) < symname(list[j])
+	})
+	return list
+}
+
+// escape text for the output format; Markdown is escaped as HTML too
+// so that <bracketed> symbols in descriptions are not taken for tags
+func docescape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+// a symbol, linked to where it is documented if it is
+func doclink(s PSYMBOL) string {
+	var name string
+
+	name = symname(s)
+	anchor, ok := _docs.anchors[s]
+	if _docs.html {
+		if !ok {
+			return docescape(name)
+		}
+		return "<a href=\"#" + anchor + "\">" + docescape(name) + "</a>"
+	}
+	if !ok {
+		return "`" + name + "`"
+	}
+	return "[`" + name + "`](#" + anchor + ")"
+}
+
+// put out a heading with an anchor
+func docheading(level int, anchor string, text string) {
+	if _docs.html {
+		fprintf(stdout, "<h%d", level)
+		if anchor != "" {
+			fprintf(stdout, " id=\"%s\"", anchor)
+		}
+		fprintf(stdout, ">%s</h%d>\n", docescape(text), level)
+		return
+	}
+	if anchor != "" {
+		fprintf(stdout, "<a id=\"%s\"></a>\n", anchor)
+	}
+	fprintf(stdout, "%s %s\n\n", strings.Repeat("#", level), docescape(text))
+}
+
+// put out a description, blank lines separating paragraphs
+func docdescription(text string) {
+	if text == "" {
+		return
+	}
+	for _, para := range strings.Split(text, "\n\n") {
+		if _docs.html {
+			fprintf(stdout, "<p>%s</p>\n", docescape(para))
+		} else {
+			fprintf(stdout, "%s\n\n", docescape(para))
+		}
+	}
+}
+
+// put out the rules of s, as writeg does but with links
+func docrules(s PSYMBOL) {
+	var p PPRODUCTION
+	var e PELEMENT
+	var lines []string
+	var words []string
+	var lead string
+
+	for p = s.data; p != nil; p = p.next {
+		words = words[:0]
+		for e = p.data; e != nil; e = e.next {
+			words = append(words, doclink(e.data))
+		}
+		if p == s.data {
+			lead = doclink(s) + " " + _format.RuleSym
+		} else if _docs.html {
+			lead = strings.Repeat(" ", len(symname(s))+1) + "|"
+		} else {
+			lead = "|"
+		}
+		lines = append(lines, lead+" "+strings.Join(words, " "))
+	}
+	if _docs.html {
+		fprintf(stdout, "<pre class=\"rule\">%s</pre>\n", strings.Join(lines, "\n"))
+	} else {
+		fprintf(stdout, "%s\n\n", strings.Join(lines, " \\\n"))
+	}
+}
+
+// put out the list of symbols using s
+func docusedby(s PSYMBOL) {
+	var links []string
+
+	for _, ss := range _docs.usedby[s] {
+		links = append(links, doclink(ss))
+	}
+	if links == nil {
+		return
+	}
+	if _docs.html {
+		fprintf(stdout, "<p class=\"usedby\">Used by: %s</p>\n", strings.Join(links, ", "))
+	} else {
+		fprintf(stdout, "Used by: %s\n\n", strings.Join(links, ", "))
+	}
+}
+
+// put out a note, a line of its own
+func docnote(text string) {
+	if _docs.html {
+		fprintf(stdout, "<p class=\"note\">%s</p>\n", text)
+	} else {
+		fprintf(stdout, "%s\n\n", text)
+	}
+}
+
+// writedocs
+// write grammar structure as cross linked documentation,
+// in HTML if format is "html", else in Markdown
+func writedocs(format string, title string) {
+	var order []PSYMBOL
+	var terminals []PSYMBOL
+	var links []string
+
+	_docs.html = format == "html"
+	order = reachorder() // leaves the reachable symbols TOUCHED
+	terminals = docterminals()
+	docsetup(order, terminals)
+
+	if _docs.html {
+		fputs("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n", stdout)
+		fprintf(stdout, "<title>%s</title>\n", docescape(title))
+		fputs("</head>\n<body>\n", stdout)
+	}
+	docheading(1, "", title)
+	docdescription(description)
+	if head != nil {
+		docnote("The distinguished symbol is " + doclink(head) + ".")
+	}
+	if emptypt != nil {
+		docnote("The empty symbol is " + doclink(emptypt) + ".")
+	}
+	for _, s := range order {
+		links = append(links, doclink(s))
+	}
+	if links != nil {
+		docnote("Rules: " + strings.Join(links, ", "))
+	}
+
+	docheading(2, "", "Rules")
+	for _, s := range order {
+		docheading(3, _docs.anchors[s], symname(s))
+		docdescription(s.comment)
+		docrules(s)
+		if s.state != TOUCHED {
+			docnote("Not reachable from the distinguished symbol.")
+		}
+		docusedby(s)
+	}
+
+	if terminals != nil {
+		docheading(2, "", "Glossary of terminals")
+		for _, s := range terminals {
+			docheading(3, _docs.anchors[s], symname(s))
+			docusedby(s)
+		}
+	}
+
+	if _docs.html {
+		fputs("</body>\n</html>\n", stdout)
+	}
+}
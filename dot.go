@@ -0,0 +1,246 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"strings"
+)
+
+// Graphviz DOT export of the symbol dependency graph.
+// There is a node for each nonterminal and an edge A -> B when B appears
+// in a production of A.  Each edge is labelled with where B appears in
+// the productions of A: left when it is the first symbol of one, right
+// when it is the last, and middle otherwise; the empty symbol and the
+// EBNF metasymbols don't count in deciding this.  The distinguished
+// symbol is drawn with a double border, symbols that can't be reached
+// from it are filled in red, and each strongly connected component with
+// a cycle in it, that is, each cluster of mutually recursive symbols, is
+// put in a box of its own.
+
+func WriteDOT() {
+	writedot()
+}
+
+// positions of a symbol in a production, as a set of bits
+const (
+	DOTLEFT   = 1 << iota // first symbol
+	DOTMIDDLE             // neither first nor last
+	DOTRIGHT              // last symbol
+)
+
+// an edge of the dependency graph
+type dotedge struct {
+	from, to PSYMBOL
+	where    int // DOTLEFT, DOTMIDDLE and DOTRIGHT bits
+}
+
+// global variables private to this package
+var _dot struct {
+	edges map[PSYMBOL][]*dotedge // edges from each symbol, in order
+	index map[PSYMBOL]int        // Tarjan's algorithm, visit order
+	low   map[PSYMBOL]int        // lowest index reachable
+	stack []PSYMBOL              // symbols not yet in a component
+	on    map[PSYMBOL]bool       // is the symbol on the stack
+	comps [][]PSYMBOL            // the strongly connected components
+}
+
+// dotedges finds the edges from nonterminal s
+func dotedges(s PSYMBOL) []*dotedge {
+	var p PPRODUCTION
+	var e PELEMENT
+	var edges []*dotedge
+	var byto map[PSYMBOL]*dotedge
+	var syms []PSYMBOL
+
+	byto = map[PSYMBOL]*dotedge{}
+	for p = s.data; p != nil; p = p.next {
+		syms = syms[:0]
+		for e = p.data; e != nil; e = e.next {
+			if _, _, ok := metakind(e.data); !ok && e.data != emptypt {
+				syms = append(syms, e.data)
+			}
+		}
+		for i, ss := range syms {
+			if TERMINAL(ss) {
+				continue
+			}
+			d := byto[ss]
+			if d == nil {
+				d = &dotedge{from: s, to: ss}
+				byto[ss] = d
+				edges = append(edges, d)
+			}
+			if i == 0 {
+				d.where |= DOTLEFT
+			}
+			if i == len(syms)-1 {
+				d.where |= DOTRIGHT
+			}
+			if i != 0 && i != len(syms)-1 {
+				d.where |= DOTMIDDLE
+			}
+		}
+	}
+	return edges
+}
+
+// Tarjan's strongly connected components algorithm
+func dotconnect(s PSYMBOL) {
+	var comp []PSYMBOL
+	var ss PSYMBOL
+
+	_dot.index[s] = len(_dot.index)
+	_dot.low[s] = _dot.index[s]
+	_dot.stack = append(_dot.stack, s)
+	_dot.on[s] = true
+
+	for _, d := range _dot.edges[s] {
+		if _, seen := _dot.index[d.to]; !seen {
+			dotconnect(d.to)
+			_dot.low[s] = min(_dot.low[s], _dot.low[d.to])
+		} else if _dot.on[d.to] {
+			_dot.low[s] = min(_dot.low[s], _dot.index[d.to])
+		}
+	}
+
+	if _dot.low[s] == _dot.index[s] {
+		// s is the root of a component, pop it off the stack
+		for {
+			ss = _dot.stack[len(_dot.stack)-1]
+			_dot.stack = _dot.stack[:len(_dot.stack)-1]
+			_dot.on[ss] = false
+			comp = append(comp, ss)
+			if ss == s {
+				break
+			}
+		}
+		_dot.comps = append(_dot.comps, comp)
+	}
+}
+
+// recursive reports whether a component has a cycle in it
+func dotrecursive(comp []PSYMBOL) bool {
+	if len(comp) > 1 {
+		return true
+	}
+	for _, d := range _dot.edges[comp[0]] {
+		if d.to == comp[0] {
+			return true
+		}
+	}
+	return false
+}
+
+// quote a string as a DOT identifier
+func dotquote(s string) string {
+	return `"` + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), `"`, `\"`) + `"`
+}
+
+// the label of an edge
+func dotlabel(where int) string {
+	var parts []string
+
+	if where&DOTLEFT != 0 {
+		parts = append(parts, "left")
+	}
+	if where&DOTMIDDLE != 0 {
+		parts = append(parts, "middle")
+	}
+	if where&DOTRIGHT != 0 {
+		parts = append(parts, "right")
+	}
+	return strings.Join(parts, ",")
+}
+
+// put out the node for s
+func dotnode(s PSYMBOL, indent string) {
+	var attrs []string
+
+	if s == head {
+		attrs = append(attrs, "peripheries=2")
+	}
+	if s.state != TOUCHED {
+		attrs = append(attrs, "style=filled", "fillcolor=\"#ffb0b0\"", "tooltip=\"unreachable\"")
+	}
+	fputs(indent+dotquote(symname(s)), stdout)
+	if attrs != nil {
+		fputs(" ["+strings.Join(attrs, ", ")+"]", stdout)
+	}
+	fputs(";\n", stdout)
+}
+
+// writedot
+// write the dependency graph of the nonterminals as Graphviz DOT
+func writedot() {
+	var order []PSYMBOL
+	var clustered map[PSYMBOL]bool
+	var n int
+
+	order = reachorder() // leaves the reachable symbols TOUCHED
+
+	_dot.edges = map[PSYMBOL][]*dotedge{}
+	for _, s := range order {
+		_dot.edges[s] = dotedges(s)
+	}
+	_dot.index = map[PSYMBOL]int{}
+	_dot.low = map[PSYMBOL]int{}
+	_dot.on = map[PSYMBOL]bool{}
+	_dot.stack = nil
+	_dot.comps = nil
+	for _, s := range order {
+		if _, seen := _dot.index[s]; !seen {
+			dotconnect(s)
+		}
+	}
+
+	fputs("digraph grammar {\n", stdout)
+	fputs("  node [shape=box];\n", stdout)
+
+	// recursive clusters first, in the order their first symbol is met
+	clustered = map[PSYMBOL]bool{}
+	for _, s := range order {
+		if clustered[s] {
+			continue
+		}
+		for _, comp := range _dot.comps {
+			if !dotrecursive(comp) || !inlist(s, comp) {
+				continue
+			}
+			n++
+			fprintf(stdout, "  subgraph cluster_%d {\n", n)
+			fputs("    label=\"recursive\";\n", stdout)
+			fputs("    style=rounded;\n", stdout)
+			for _, ss := range order {
+				if inlist(ss, comp) {
+					dotnode(ss, "    ")
+					clustered[ss] = true
+				}
+			}
+			fputs("  }\n", stdout)
+		}
+	}
+	for _, s := range order {
+		if !clustered[s] {
+			dotnode(s, "  ")
+		}
+	}
+
+	for _, s := range order {
+		for _, d := range _dot.edges[s] {
+			fprintf(stdout, "  %s -> %s [label=%s];\n",
+				dotquote(symname(d.from)), dotquote(symname(d.to)), dotquote(dotlabel(d.where)))
+		}
+	}
+	fputs("}\n", stdout)
+}
+
+// inlist reports whether s is in list
+func inlist(s PSYMBOL, list []PSYMBOL) bool {
+	for _, ss := range list {
+		if ss == s {
+			return true
+		}
+	}
+	return false
+}
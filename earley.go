@@ -0,0 +1,136 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+// Recognise strings of terminals by Earley's algorithm.
+// Any grammar will do, ambiguous or left recursive, as long as it is in
+// BNF, since the brackets of EBNF are taken as terminals.  For each place
+// in the string, from before the first terminal to after the last, there
+// is a set of items, each a rule with a mark in it saying how much of the
+// rule has been matched, and the place the match of the rule started
+//    |
+//    |<expression> ::= <expression> + . <term>   from 0
+//    |
+// The items are gone over in turn, each adding others: one with a
+// nonterminal after the mark predicts the rules of the nonterminal from
+// here, one with a terminal after the mark scans it, moving the mark on
+// in the next set if the next terminal of the string is it, and one with
+// the mark at the end completes, moving the mark on over its symbol in
+// the items it was predicted from.  The string is in the language if the
+// last set has a rule of the distinguished symbol, complete, from 0.  As
+// Aycock and Horspool have it, the mark also moves on at once over a
+// symbol that can be empty, so that completing an empty rule need not go
+// back over the items of the set it is in.  The symbols that can be empty
+// are found as markempty finds them, but starting from none rather than
+// all, so that a rule like <a> ::= <a> <a> doesn't make <a> one of them.
+
+// an item, rule p of lhs matched up to e, from the place origin
+type earleyitem struct {
+	lhs    PSYMBOL
+	p      PPRODUCTION
+	e      PELEMENT // the element after the mark, nil at the end
+	origin int
+}
+
+// the items of one place in the string, in the order they were added
+type earleyset struct {
+	items []earleyitem
+	seen  map[earleyitem]bool
+}
+
+// earleyadd adds item to set, moving the mark past the empty symbol
+func earleyadd(set *earleyset, item earleyitem) {
+	for item.e != nil && item.e.data == emptypt {
+		item.e = item.e.next
+	}
+	if !set.seen[item] {
+		set.seen[item] = true
+		set.items = append(set.items, item)
+	}
+}
+
+// earleyempty finds the nonterminals that derive the empty string
+func earleyempty() map[PSYMBOL]bool {
+	var empty map[PSYMBOL]bool
+	var s PSYMBOL
+	var p PPRODUCTION
+	var e PELEMENT
+	var change bool
+
+	empty = map[PSYMBOL]bool{}
+	if emptypt != nil {
+		empty[emptypt] = true
+	}
+	// do {...} while (change)
+	for firstTime := true; firstTime || change; firstTime = false {
+		change = false
+		for s = symlist; s != nil; s = s.next {
+			for p = s.data; p != nil && !empty[s]; p = p.next {
+				for e = p.data; e != nil && empty[e.data]; e = e.next {
+				}
+				if e == nil {
+					empty[s] = true
+					change = true
+				}
+			}
+		}
+	}
+	return empty
+}
+
+// recognise reports whether the distinguished symbol derives tokens,
+// a string of terminals
+func recognise(tokens []PSYMBOL) bool {
+	var sets []earleyset
+	var item earleyitem
+	var s PSYMBOL
+	var p PPRODUCTION
+	var empty map[PSYMBOL]bool
+
+	if head == nil || TERMINAL(head) {
+		return false
+	}
+	empty = earleyempty()
+	sets = make([]earleyset, len(tokens)+1)
+	for i := range sets {
+		sets[i].seen = map[earleyitem]bool{}
+	}
+	for p = head.data; p != nil; p = p.next {
+		earleyadd(&sets[0], earleyitem{head, p, p.data, 0})
+	}
+
+	for i := range sets {
+		for j := 0; j < len(sets[i].items); j++ {
+			item = sets[i].items[j]
+			if item.e == nil { // complete
+				for _, from := range sets[item.origin].items {
+					if from.e != nil && from.e.data == item.lhs {
+						earleyadd(&sets[i], earleyitem{from.lhs, from.p, from.e.next, from.origin})
+					}
+				}
+				continue
+			}
+			s = item.e.data
+			if TERMINAL(s) { // scan
+				if i < len(tokens) && tokens[i] == s {
+					earleyadd(&sets[i+1], earleyitem{item.lhs, item.p, item.e.next, item.origin})
+				}
+				continue
+			}
+			for p = s.data; p != nil; p = p.next { // predict
+				earleyadd(&sets[i], earleyitem{s, p, p.data, i})
+			}
+			if empty[s] {
+				earleyadd(&sets[i], earleyitem{item.lhs, item.p, item.e.next, item.origin})
+			}
+		}
+	}
+
+	for _, item = range sets[len(tokens)].items {
+		if item.lhs == head && item.e == nil && item.origin == 0 {
+			return true
+		}
+	}
+	return false
+}
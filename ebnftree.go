@@ -0,0 +1,369 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+// EBNF rule trees.
+// readg keeps EBNF as a kluge: the metasymbols ( ) [ ] { } are read as
+// ordinary terminals and a vertical bar inside brackets splits the rule
+// into separate productions, so
+//    |
+//    |<a> ::= <b> ( <c> | <d> ) <e>
+//    |
+// is held as the two productions "<b> ( <c>" and "<d> ) <e>".  Readers for
+// other notations build a tree of nodes and then hang it from a symbol in
+// the same form, so that writeg and gdeebnf see what readg would have made.
+
+// kinds of node in a rule tree
+const (
+	GSYMBOL = iota // a reference to a symbol
+	GSEQ           // a sequence, possibly empty
+	GALT           // alternatives, ( a | b )
+	GOPT           // optional alternatives, [ a | b ]
+	GREP           // repeated alternatives, { a | b }
+)
+
+type gnode struct {
+	kind int      // one of the kinds above
+	sym  PSYMBOL  // the symbol referenced by a GSYMBOL node
+	kids []*gnode // the members of a GSEQ, or alternatives of the others
+	line int      // source line number on which the node starts
+	pos  span     // where a GSYMBOL node or a bracketed group is
+}
+
+// plainbnf is set when ( ) [ ] { } are to be treated as terminals
+var plainbnf bool
+
+func SetPlainBNF(b bool) {
+	plainbnf = b
+}
+
+// the names of the metasymbols, indexed by kind, open then close
+var metanames = [...][2]string{
+	GALT: {"(", ")"},
+	GOPT: {"[", "]"},
+	GREP: {"{", "}"},
+}
+
+// metakind reports whether s is one of the EBNF metasymbols,
+// returning the kind of group it belongs to and whether it opens it
+func metakind(s PSYMBOL) (kind int, open bool, ok bool) {
+	if NONTERMINAL(s) || stringtab[s.name] != 1 {
+		return 0, false, false
+	}
+	return metaname(string(stringtab[s.name+1 : s.name+2]))
+}
+
+// metaname reports whether readg would take name for one of the EBNF
+// metasymbols, as metakind does
+func metaname(name string) (kind int, open bool, ok bool) {
+	if plainbnf {
+		return 0, false, false
+	}
+	for kind, names := range metanames {
+		if name == names[0] {
+			return kind, true, true
+		} else if name == names[1] {
+			return kind, false, true
+		}
+	}
+	return 0, false, false
+}
+
+// nesting is the bracket nesting at the end of the elements e, when it
+// is depth at the start of them
+func nesting(e PELEMENT, depth int) int {
+	for ; e != nil; e = e.next {
+		if _, open, ok := metakind(e.data); !ok {
+			continue
+		} else if open {
+			depth++
+		} else if depth > 0 {
+			depth--
+		}
+	}
+	return depth
+}
+
+// needempty returns the empty symbol, inventing an empty quoted one
+// if the grammar has not declared one
+func needempty() PSYMBOL {
+	if emptypt == nil {
+		emptypt = namedsym("''")
+	}
+	return emptypt
+}
+
+// tree flattening
+
+type flattener struct {
+	s  PSYMBOL     // the symbol the productions hang from
+	p  PPRODUCTION // the production being filled
+	pe *PELEMENT   // where the next element goes in p
+}
+
+// start a new production at the end of the rules of s
+func (f *flattener) newprod(line int) {
+	var pp *PPRODUCTION
+
+	pp = &(f.s.data)
+	for *pp != nil {
+		pp = &((*pp).next)
+	}
+	f.p = NEWPRODUCTION()
+	f.p.line = line
+	f.p.state = UNTOUCHED
+	*pp = f.p
+	f.pe = &(f.p.data)
+}
+
+// add an element referencing ss to the production being filled
+func (f *flattener) add(ss PSYMBOL, line int, pos span) {
+	var e PELEMENT
+
+	e = NEWELEMENT()
+	e.line = line
+	e.pos = pos
+	e.data = ss
+	*f.pe = e
+	f.pe = &(e.next)
+	f.p.pos = elemspan(f.p.data)
+}
+
+func (f *flattener) emit(t *gnode) {
+	switch t.kind {
+	case GSYMBOL:
+		f.add(t.sym, t.line, t.pos)
+	case GSEQ:
+		if len(t.kids) == 0 {
+			f.add(needempty(), t.line, t.pos)
+		}
+		for _, k := range t.kids {
+			f.emit(k)
+		}
+	default:
+		// the metasymbols may not be in the source, put them at the
+		// start and end of the group
+		f.add(namedsym(metanames[t.kind][0]), t.line, spanstart(t.pos))
+		for i, k := range t.kids {
+			if i > 0 { // a bar inside brackets starts a new production
+				f.newprod(k.line)
+			}
+			f.emit(k)
+		}
+		f.add(namedsym(metanames[t.kind][1]), t.line, spanend(t.pos))
+	}
+}
+
+// treetorules adds the alternatives of rule tree t to the productions
+// of nonterminal s; alternatives nested in t are written with the
+// metasymbols ( ) [ ] { } so the result is what readg would have made
+func treetorules(s PSYMBOL, t *gnode) {
+	var f flattener
+	var alts []*gnode
+
+	f.s = s
+	if t.kind == GALT {
+		alts = t.kids
+	} else {
+		alts = []*gnode{t}
+	}
+	for _, a := range alts {
+		f.newprod(a.line)
+		f.emit(a)
+	}
+}
+
+// tree building
+
+type treebuilder struct {
+	p PPRODUCTION // the production being read
+	e PELEMENT    // the next element of p to read
+}
+
+// read the alternatives of a group, up to the metasymbol that closes a
+// group of the given kind, opened on line at at; GSYMBOL is used for the
+// top level of a rule, where only the end of the rules ends the group
+func (b *treebuilder) alts(kind int, line int, at span) *gnode {
+	var t *gnode
+	var seq *gnode
+
+	t = &gnode{kind: GALT, line: line}
+	seq = &gnode{kind: GSEQ, line: line}
+	if b.p != nil {
+		seq.line = b.p.line
+	}
+	for {
+		if b.e == nil { // end of a production, a bar in the source
+			t.kids = append(t.kids, seq)
+			if b.p == nil || b.p.next == nil {
+				if kind != GSYMBOL {
+					errorin("MISSING "+metanames[kind][1], line, at)
+				}
+				b.p = nil
+				return t
+			}
+			b.p = b.p.next
+			b.e = b.p.data
+			seq = &gnode{kind: GSEQ, line: b.p.line}
+			continue
+		}
+
+		e := b.e
+		b.e = e.next
+		if k, open, ok := metakind(e.data); ok {
+			if open {
+				g := b.alts(k, e.line, e.pos)
+				g.kind = k
+				g.pos = spanto(e.pos, g.pos)
+				seq.kids = append(seq.kids, g)
+			} else if k == kind {
+				t.kids = append(t.kids, seq)
+				t.pos = e.pos // the caller puts in where the group starts
+				return t
+			} else {
+				errorin("UNEXPECTED "+metanames[k][1], e.line, e.pos)
+			}
+		} else if e.data != emptypt {
+			seq.kids = append(seq.kids, &gnode{kind: GSYMBOL, sym: e.data, line: e.line, pos: e.pos})
+		}
+	}
+}
+
+// rulestotree returns the productions of nonterminal s as a GALT tree,
+// with the bracketed groups that readg leaves as metasymbols turned into
+// nodes and references to the empty symbol dropped
+func rulestotree(s PSYMBOL) *gnode {
+	var b treebuilder
+
+	b.p = s.data
+	if b.p != nil {
+		b.e = b.p.data
+	}
+	return b.alts(GSYMBOL, s.line, span{})
+}
+
+// isinvented reports whether nonterminal s looks like a symbol that
+// gdeebnf made up for a bracketed group in the rules of parent, that is,
+// its name is the name of parent with -a, -b and so on added to it
+func isinvented(s PSYMBOL, parent PSYMBOL) bool {
+	var name, base string
+	var i int
+
+	name = symname(s)
+	base = symname(parent)
+	if (isbracketed(base) || isquoted(base)) && len(name) > 0 && name[len(name)-1] == base[len(base)-1] {
+		// the extension goes inside the brackets or quotes
+		name = name[:len(name)-1]
+		base = base[:len(base)-1]
+	}
+	if len(name) < len(base)+2 || name[:len(base)] != base || name[len(base)] != '-' {
+		return false
+	}
+	for i = len(base) + 1; i < len(name); i++ {
+		if name[i] < 'a' || name[i] > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// uses counts the references to each symbol from the rules of other symbols
+func uses() map[PSYMBOL]int {
+	var s PSYMBOL
+	var p PPRODUCTION
+	var e PELEMENT
+	var count map[PSYMBOL]int
+
+	count = map[PSYMBOL]int{}
+	for s = symlist; s != nil; s = s.next {
+		for p = s.data; p != nil; p = p.next {
+			for e = p.data; e != nil; e = e.next {
+				if e.data != s {
+					count[e.data]++
+				}
+			}
+		}
+	}
+	return count
+}
+
+// rebracket replaces references in t to symbols that gdeebnf invented,
+// and that are used only once, with the group the symbol stands for, so
+// <a> ::= <b> <a-a> with <a-a> ::= <empty> | <c> <a-a> becomes the tree
+// for <a> ::= <b> { <c> }.  Symbols that are absorbed are set in absorbed.
+// Parent is the symbol whose rules t came from.
+func rebracket(t *gnode, parent PSYMBOL, count map[PSYMBOL]int, absorbed map[PSYMBOL]bool) {
+	for i, k := range t.kids {
+		if k.kind != GSYMBOL {
+			rebracket(k, parent, count, absorbed)
+			continue
+		}
+		s := k.sym
+		if TERMINAL(s) || s == head || count[s] != 1 || absorbed[s] || !isinvented(s, parent) {
+			continue
+		}
+		g := regroup(s, rulestotree(s))
+		if g != nil {
+			absorbed[s] = true
+			rebracket(g, s, count, absorbed)
+			t.kids[i] = g
+		}
+	}
+}
+
+// regroup decides what kind of group the rules g of invented symbol s
+// stand for, returns nil if they aren't a group that could be bracketed
+func regroup(s PSYMBOL, g *gnode) *gnode {
+	var empty bool
+	var loops bool
+	var rest []*gnode
+
+	loops = true
+	for _, a := range g.kids {
+		if len(a.kids) == 0 {
+			empty = true
+			continue
+		}
+		last := a.kids[len(a.kids)-1]
+		if last.kind != GSYMBOL || last.sym != s {
+			loops = false
+		}
+		for _, k := range a.kids[:len(a.kids)-1] {
+			if refers(k, s) {
+				return nil // recursive in some other way
+			}
+		}
+		rest = append(rest, a)
+	}
+	if len(rest) == 0 {
+		return nil
+	}
+	if !(empty && loops) && refers(g, s) {
+		return nil
+	}
+	if empty && loops {
+		// <s> ::= <empty> | ... <s>, iteration
+		for i, a := range rest {
+			rest[i] = &gnode{kind: GSEQ, kids: a.kids[:len(a.kids)-1], line: a.line}
+		}
+		return &gnode{kind: GREP, kids: rest, line: g.line, pos: s.pos}
+	} else if empty {
+		return &gnode{kind: GOPT, kids: rest, line: g.line, pos: s.pos}
+	}
+	g.pos = s.pos
+	return g
+}
+
+// refers reports whether tree t refers to symbol s anywhere
+func refers(t *gnode, s PSYMBOL) bool {
+	if t.kind == GSYMBOL {
+		return t.sym == s
+	}
+	for _, k := range t.kids {
+		if refers(k, s) {
+			return true
+		}
+	}
+	return false
+}
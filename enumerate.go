@@ -0,0 +1,253 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Enumerate all the strings the grammar derives.
+// Where sample picks one derivation at random, this lists every string
+// of terminals the distinguished symbol derives up to a number of tokens,
+// or by derivations up to a depth, or both, one string to a line in
+// shortlex order, the shorter strings first and strings of one length in
+// the order of their tokens, each string once however many derivations
+// it has.  The strings of each length are written as soon as they are
+// known, so a long run can be cut short with what it found so far.
+//
+// The strings of n tokens that a nonterminal derives come from those of
+// fewer tokens that the symbols of its rules derive, and from those of n
+// tokens of the symbols that can be empty, so they are found for n = 0,
+// 1, 2 ... in turn, going over the rules until nothing is added.  The
+// markings of deempty say which symbols can be empty, which must be, and
+// which can't, so a rule is only split in the ways that can work.  With
+// a depth, the strings of a nonterminal at depth d come from those of
+// the symbols of its rules at depth d-1, a terminal having depth 0.
+
+// global variables private to this package
+var _enum struct {
+	depth int                  // the depth derivations are limited to, 0 for none
+	sets  map[enumkey][]string // the strings found, tokens separated by NUL
+	seen  map[enumkey]map[string]bool
+	add   bool // something was added on this pass
+}
+
+// the strings of n tokens that s derives at depth d, d being 0 with no limit
+type enumkey struct {
+	s PSYMBOL
+	n int
+	d int
+}
+
+// Enumerate writes the strings of up to length tokens that the
+// distinguished symbol derives by derivations up to depth levels deep, or
+// that start derives if it isn't "", where 0 is no limit on either one
+func Enumerate(start string, length, depth int) error {
+	return enumerate(start, length, depth)
+}
+
+// enumget lists the strings of n tokens that s derives at depth d
+func enumget(s PSYMBOL, n, d int) []string {
+	if s == emptypt {
+		if n == 0 {
+			return []string{""}
+		}
+		return nil
+	} else if TERMINAL(s) {
+		if n == 1 {
+			return []string{symname(s)}
+		}
+		return nil
+	}
+	return _enum.sets[enumkey{s, n, d}]
+}
+
+// enumput notes that s derives str, of n tokens, at depth d
+func enumput(s PSYMBOL, n, d int, str string) {
+	var key enumkey
+
+	key = enumkey{s, n, d}
+	if _enum.seen[key] == nil {
+		_enum.seen[key] = map[string]bool{}
+	}
+	if !_enum.seen[key][str] {
+		_enum.seen[key][str] = true
+		_enum.sets[key] = append(_enum.sets[key], str)
+		_enum.add = true
+	}
+}
+
+// enumleast is the fewest tokens the symbols from e on can derive, as far
+// as the markings of deempty tell
+func enumleast(e PELEMENT) int {
+	var n int
+
+	for ; e != nil; e = e.next {
+		if e.data.state == NONEMPTY {
+			n = n + 1
+		}
+	}
+	return n
+}
+
+// enumrule adds the strings of left tokens the elements from e on derive,
+// following the tokens in prefix, to the strings of n tokens s derives
+// at depth d, with the symbols of the rule taken at depth sub
+func enumrule(s PSYMBOL, e PELEMENT, left, n, d, sub int, prefix string) {
+	var lo, hi int
+	var str string
+
+	if e == nil {
+		if left == 0 {
+			enumput(s, n, d, prefix)
+		}
+		return
+	}
+	lo = 0
+	hi = left - enumleast(e.next)
+	switch e.data.state {
+	case NONEMPTY:
+		lo = 1
+	case ISEMPTY:
+		hi = 0
+	}
+	for m := lo; m <= hi; m++ {
+		for _, str = range enumget(e.data, m, sub) {
+			if prefix != "" && str != "" {
+				str = prefix + "\x00" + str
+			} else if prefix != "" {
+				str = prefix
+			}
+			enumrule(s, e.next, left-m, n, d, sub, str)
+		}
+	}
+}
+
+// enumlevel finds the strings of n tokens that the nonterminals derive
+func enumlevel(n int) {
+	var s PSYMBOL
+	var p PPRODUCTION
+
+	if _enum.depth == 0 {
+		// a rule can use strings of n tokens found on this pass
+		for firstTime := true; firstTime || _enum.add; firstTime = false {
+			_enum.add = false
+			for s = symlist; s != nil; s = s.next {
+				for p = s.data; p != nil; p = p.next {
+					enumrule(s, p.data, n, n, 0, 0, "")
+				}
+			}
+		}
+		return
+	}
+	for d := 1; d <= _enum.depth; d++ {
+		for s = symlist; s != nil; s = s.next {
+			for p = s.data; p != nil; p = p.next {
+				enumrule(s, p.data, n, n, d, d-1, "")
+			}
+		}
+	}
+}
+
+// enumlongest is the most tokens s derives at depth d, or -1 if it
+// derives nothing at that depth, found for all the symbols a depth at a
+// time since the depths below d would be gone over many times otherwise
+func enumlongest(s PSYMBOL, d int) int {
+	var longest, below map[PSYMBOL]int
+	var ss PSYMBOL
+	var p PPRODUCTION
+	var e PELEMENT
+	var n int
+
+	if s == emptypt {
+		return 0
+	} else if TERMINAL(s) {
+		return 1
+	}
+	longest = map[PSYMBOL]int{} // at depth 0, as far as nonterminals go
+	for ss = symlist; ss != nil; ss = ss.next {
+		if ss == emptypt {
+			longest[ss] = 0
+		} else if TERMINAL(ss) {
+			longest[ss] = 1
+		} else {
+			longest[ss] = -1
+		}
+	}
+	for ; d > 0; d-- {
+		below = longest
+		longest = map[PSYMBOL]int{}
+		for ss = symlist; ss != nil; ss = ss.next {
+			longest[ss] = below[ss]
+			for p = ss.data; p != nil; p = p.next {
+				n = 0
+				for e = p.data; e != nil && n >= 0; e = e.next {
+					if below[e.data] < 0 {
+						n = -1
+					} else {
+						n = n + below[e.data]
+					}
+				}
+				if n > longest[ss] {
+					longest[ss] = n
+				}
+			}
+		}
+	}
+	return longest[s]
+}
+
+// enumwrite writes the strings of n tokens that s derives, in order
+func enumwrite(s PSYMBOL, n int) {
+	var strs []string
+
+	strs = append(strs, enumget(s, n, _enum.depth)...)
+	sort.Strings(strs) // NUL sorts before any character of a token
+	for _, str := range strs {
+		fputs(strings.ReplaceAll(str, "\x00", " "), stdout)
+		fputs("\n", stdout)
+	}
+}
+
+// enumerate
+// write the strings derived by start, or the distinguished symbol
+func enumerate(start string, length, depth int) error {
+	var s PSYMBOL
+	var most int
+
+	if length <= 0 && depth <= 0 {
+		return fmt.Errorf("enumerate needs a length or a depth")
+	}
+	s = head
+	if start != "" {
+		if s = lookupname(start); s == nil {
+			return fmt.Errorf("no symbol %s", start)
+		}
+	} else if s == nil {
+		return fmt.Errorf("no distinguished symbol")
+	}
+	markempty()
+	_enum.depth = depth
+	if depth < 0 {
+		_enum.depth = 0
+	}
+	_enum.sets = map[enumkey][]string{}
+	_enum.seen = map[enumkey]map[string]bool{}
+
+	most = length
+	if _enum.depth > 0 {
+		if longest := enumlongest(s, _enum.depth); length <= 0 || longest < most {
+			most = longest
+		}
+	}
+	for n := 0; n <= most; n++ {
+		enumlevel(n)
+		enumwrite(s, n)
+	}
+	_enum.sets = nil
+	_enum.seen = nil
+	return nil
+}
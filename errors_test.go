@@ -0,0 +1,42 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+// the errors in errors.gr are reported as errors.diag.json has them, as
+// gdeebnf -format json -input grammars/errors.gr reports them
+func TestErrorsDiagnostics(t *testing.T) {
+	var diags []Diagnostic
+	var got bytes.Buffer
+
+	want, err := os.ReadFile("grammars/errors.diag.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = SetStdin("grammars/errors.gr"); err != nil {
+		t.Fatal(err)
+	}
+	SetStdout(io.Discard)
+	defer SetStdout(os.Stdout)
+	SetDiagnostics(func(d Diagnostic) {
+		diags = append(diags, d)
+	})
+	defer SetDiagnostics(nil)
+
+	ReadGrammar()
+	GDeEBNF()
+	WriteGrammar()
+	if err = FormatDiagnostics(&got, "json", diags); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("diagnostics for grammars/errors.gr differ from grammars/errors.diag.json, got\n%s", got.String())
+	}
+}
@@ -38,17 +38,19 @@ func addemptyrule(s PSYMBOL) {
 	var e PELEMENT    // a new element <empty>
 
 	if emptypt == nil {
-		errormsg("EMPTY SYMBOL MUST BE DEFINED", s.data.line)
+		errorin("EMPTY SYMBOL MUST BE DEFINED", s.data.line, s.data.pos)
 		return // quit if can't add empty rule
 	}
 
 	e = NEWELEMENT()
 	e.line = s.data.line // take the line number from its first rule
+	e.pos = spanstart(s.pos)
 	e.next = nil
 	e.data = emptypt
 
 	p = NEWPRODUCTION()
 	p.line = s.data.line // take the line number as above
+	p.pos = e.pos
 	p.next = s.data
 	p.data = e
 	p.state = UNTOUCHED
@@ -69,6 +71,8 @@ func extractuntil(s PSYMBOL, p PPRODUCTION, e PELEMENT, rsym PSYMBOL, nsc *int)
 	var ne PELEMENT    // first element within parens starts body of rule
 	var lsym PSYMBOL   // the left brace that balances rsym
 	var nest int       // bracket nesting level
+	var last span      // where the last element of the group seen is
+	var from []span    // the source productions of p before any swiping
 
 	lsym = e.data // we were called with left brace current element
 
@@ -76,9 +80,15 @@ func extractuntil(s PSYMBOL, p PPRODUCTION, e PELEMENT, rsym PSYMBOL, nsc *int)
 	ns = inventsymbol(s, nsc)
 	ns.line = e.line
 
+	// the rules of ns are made from p and any rules swiped from after it
+	p.from = origins(p)
+	from = p.from
+	last = e.pos
+
 	// make the first new rule that will hang under ns
 	nr = NEWPRODUCTION()
 	nr.line = e.line
+	nr.from = p.from
 	nr.state = UNTOUCHED
 	nr.next = nil
 	nr.data = ne
@@ -97,12 +107,13 @@ func extractuntil(s PSYMBOL, p PPRODUCTION, e PELEMENT, rsym PSYMBOL, nsc *int)
 		if ee == nil {
 			// we hit the end of a rule, either because of missing end bracket or bracketed alternatives
 			if nr.data == nil { // previous rule was empty!
-				errormsg("EMPTY BRACKETED RULE", nr.line)
+				errorin("EMPTY BRACKETED RULE", nr.line, spanend(last))
 				// add empty element to rule if possible
 				if emptypt != nil {
 					ne = NEWELEMENT()
 					nr.data = ne
 					ne.line = nr.line
+					ne.pos = spanend(last)
 					ne.next = nil
 					ne.data = emptypt
 					ne = ee
@@ -119,6 +130,8 @@ func extractuntil(s PSYMBOL, p PPRODUCTION, e PELEMENT, rsym PSYMBOL, nsc *int)
 			nr = nr.next
 			p.next = nr.next
 			nr.next = nil
+			addorigins(p, nr)
+			nr.from = origins(nr)
 
 			// move to next rule looking for end paren
 			eep = &(nr.data)
@@ -129,6 +142,7 @@ func extractuntil(s PSYMBOL, p PPRODUCTION, e PELEMENT, rsym PSYMBOL, nsc *int)
 			// scan for end paren, accounting for nesting
 			if ee.data == rsym {
 				if nest == 0 { // quit loop
+					last = ee.pos
 					break
 				}
 				nest = nest - 1
@@ -137,6 +151,7 @@ func extractuntil(s PSYMBOL, p PPRODUCTION, e PELEMENT, rsym PSYMBOL, nsc *int)
 			}
 
 			// march down this rule looking for end paren
+			last = ee.pos
 			eep = &(ee.next)
 			ee = *eep
 		}
@@ -147,11 +162,12 @@ func extractuntil(s PSYMBOL, p PPRODUCTION, e PELEMENT, rsym PSYMBOL, nsc *int)
 		e.next = ee.next // snip bracketed body out of rule
 
 		if nr.data == nil { // final rule of set was empty!
-			errormsg("EMPTY BRACKETED RULE", ee.line)
+			errorin("EMPTY BRACKETED RULE", ee.line, spanstart(ee.pos))
 			// add empty element to rule if possible
 			if emptypt != nil {
 				nr.data = NEWELEMENT()
 				nr.data.line = nr.line
+				nr.data.pos = spanstart(ee.pos)
 				nr.data.next = nil
 				nr.data.data = emptypt
 			}
@@ -161,14 +177,32 @@ func extractuntil(s PSYMBOL, p PPRODUCTION, e PELEMENT, rsym PSYMBOL, nsc *int)
 		e.next = nil /* snip body out of rule */
 
 		if rsym == rparen {
-			errormsg("MISSING )", nr.line)
+			errorin("MISSING )", e.line, e.pos)
 		} else if rsym == rsquare {
-			errormsg("MISSING ]", nr.line)
+			errorin("MISSING ]", e.line, e.pos)
 		} else /* rsym == rcurly */ {
-			errormsg("MISSING }", nr.line)
+			errorin("MISSING }", e.line, e.pos)
 		}
 		// assert complaint about ne == nil was already done
+
+		// the group ends with p, so give back the rules swiped from after
+		// it rather than losing the rest of the rule into the group
+		last = elemspan(ns.data.data)
+		if last.line == 0 {
+			last = e.pos
+		}
+		p.next = ns.data.next
+		p.from = from
+		ns.data.next = nil
+	}
+
+	// ns and the element now referring to it stand for the whole group
+	ns.pos = spanto(e.pos, last)
+	e.pos = ns.pos
+	for nr = ns.data; nr != nil; nr = nr.next {
+		nr.pos = elemspan(nr.data)
 	}
+	p.pos = elemspan(p.data)
 	return ns
 }
 
@@ -189,7 +223,7 @@ func getdelsym(str []byte) PSYMBOL {
 	}
 
 	if s.data != nil { // it's nonterminal?
-		errormsg("BRACE SHOULD BE NONTERMINAL", s.data.line)
+		errorin("BRACE SHOULD BE NONTERMINAL", s.data.line, s.data.pos)
 	}
 
 	// now find pss, pointer to s in symlist -- we know it's there
@@ -287,22 +321,26 @@ func makeiterative(s PSYMBOL) {
 	var e PELEMENT    // an element of p
 	var pe *PELEMENT  // the pointer to e
 	var line int      // best guess at source line number for empty element
+	var pos span      // where the element goes
 
 	// add a self reference to end of each rule of s
 	for p = s.data; p != nil; p = p.next { // for each production
 		// find the end of the production
 		pe = &(p.data)
 		line = p.line
+		pos = spanstart(p.pos)
 		e = *pe
 		for e != nil {
 			pe = &(e.next)
 			line = e.line
+			pos = spanend(e.pos)
 			e = *pe
 		}
 
 		// tack on a new element at the end that references s
 		e = NEWELEMENT()
 		e.line = line
+		e.pos = pos
 		e.next = nil
 		e.data = s
 		*pe = e
@@ -333,17 +371,17 @@ func process(s PSYMBOL) {
 		for e != nil {
 			// for each element of rule e
 			if e.data == rparen { // syntax error
-				errormsg("UNEXPECTED )", e.line)
+				errorin("UNEXPECTED )", e.line, e.pos)
 				e = e.next
 				*ep = e // clip it from rule
 
 			} else if e.data == rsquare { // syntax error
-				errormsg("UNEXPECTED ]", e.line)
+				errorin("UNEXPECTED ]", e.line, e.pos)
 				e = e.next
 				*ep = e /* clip it from rule */
 
 			} else if e.data == rcurly { // syntax error
-				errormsg("UNEXPECTED }", e.line)
+				errorin("UNEXPECTED }", e.line, e.pos)
 				e = e.next
 				*ep = e /* clip it from rule */
 
@@ -0,0 +1,318 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"strings"
+)
+
+// Grammar differences.
+// RememberGrammar keeps a copy of the global grammar structure, and then
+// DiffGrammar compares the grammar read after it with the copy, reporting
+// what changed in the grammar rather than in its text:
+//    |
+//    |head <expression> -> <statement>
+//    |added nonterminal <statement>
+//    |removed terminal <identifier>
+//    |<factor> became nonterminal
+//    |added rule <statement> ::= <expression> ;
+//    |removed rule <element> ::= <identifier>
+//    |changed rule <term> ::= <term> / <factor>
+//    |          to <term> ::= <term> % <factor>
+//    |no longer reachable <element>
+//    |now reachable <factor>
+//    |not reachable <unused>
+//    |
+// Rules are compared as the alternatives of rulestotree, so a rule with
+// brackets is one rule however readg split it into productions.  They
+// are matched per symbol by their text, and within a symbol an unmatched
+// old rule and an unmatched new rule are reported as one change, in the
+// order they come, each shown with the empty symbol of its own grammar
+// for an empty alternative.  The EBNF metasymbols and the empty symbol
+// are left out of the lists of symbols.  An added symbol is reported as now
+// reachable, or not reachable, as well as added.
+
+func RememberGrammar() {
+	remembergrammar()
+}
+
+func DiffGrammar() bool {
+	return diffgrammar()
+}
+
+// a symbol of the remembered grammar
+type diffsym struct {
+	name      string
+	terminal  bool
+	reachable bool
+	rules     []diffalt // the alternatives of its rules
+}
+
+// an alternative of a rule
+type diffalt struct {
+	words []string // its words, without the empty symbol, to compare
+	text  string   // its words as shown, with the empty symbol of its grammar
+}
+
+// global variables private to this package
+var _diff struct {
+	head  string    // the distinguished symbol, "" if none
+	empty string    // the empty symbol, "" if none
+	syms  []diffsym // the symbols, in symlist order
+	found bool      // a difference has been reported
+}
+
+// diffignored reports whether s is left out of the lists of symbols
+func diffignored(s PSYMBOL) bool {
+	_, _, meta := metakind(s)
+	return meta || s == emptypt
+}
+
+// void remembergrammar()
+// keep a copy of the global grammar structure for diffgrammar
+func remembergrammar() {
+	var s PSYMBOL
+
+	_diff.head = ""
+	if head != nil {
+		_diff.head = symname(head)
+	}
+	_diff.empty = ""
+	if emptypt != nil {
+		_diff.empty = symname(emptypt)
+	}
+
+	reachsetup()
+	if head != nil {
+		reachtouch(head)
+	}
+	_diff.syms = nil
+	for s = symlist; s != nil; s = s.next {
+		if diffignored(s) {
+			continue
+		}
+		ds := diffsym{name: symname(s), terminal: TERMINAL(s), reachable: s.state == TOUCHED}
+		ds.rules = diffalts(s)
+		_diff.syms = append(_diff.syms, ds)
+	}
+}
+
+// put out one line of the report
+func diffline(text string) {
+	fputs(text+"\n", stdout)
+	_diff.found = true
+}
+
+// the text of a rule of the symbol named name
+func diffrule(name string, alt diffalt) string {
+	return name + " " + RULESYM + " " + alt.text
+}
+
+// diffalts returns each alternative of the rules of s, with the groups
+// in them written out with their metasymbols
+func diffalts(s PSYMBOL) []diffalt {
+	var alts []diffalt
+
+	if TERMINAL(s) {
+		return nil
+	}
+	for _, a := range rulestotree(s).kids {
+		alts = append(alts, diffalt{diffwords(a, nil, false), strings.Join(diffwords(a, nil, true), " ")})
+	}
+	return alts
+}
+
+// diffwords appends the words of tree t to words, with the empty symbol
+// for an empty alternative if shown is set and there is one
+func diffwords(t *gnode, words []string, shown bool) []string {
+	switch t.kind {
+	case GSYMBOL:
+		return append(words, symname(t.sym))
+	case GSEQ:
+		if len(t.kids) == 0 && shown && emptypt != nil {
+			return append(words, symname(emptypt))
+		}
+		for _, k := range t.kids {
+			words = diffwords(k, words, shown)
+		}
+		return words
+	}
+	words = append(words, metanames[t.kind][0])
+	for i, k := range t.kids {
+		if i > 0 {
+			words = append(words, "|")
+		}
+		words = diffwords(k, words, shown)
+	}
+	return append(words, metanames[t.kind][1])
+}
+
+// samewords reports whether a and b are the same words.  This is what
+// samerule does for two productions of one grammar, but samerule compares
+// the symbols they point to, and the old grammar is gone once the new one
+// is read, and it compares the productions readg split a rule into, so it
+// would match pieces of alternatives; alternatives of rulestotree are
+// compared by the names of their symbols instead
+func samewords(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// diffrules reports the rules of s that were added, removed or changed
+// from the remembered rules old
+func diffrules(s PSYMBOL, old []diffalt) {
+	var alts []diffalt
+	var matched []bool
+	var removed []diffalt
+	var added []diffalt
+	var name string
+
+	name = symname(s)
+	alts = diffalts(s)
+	matched = make([]bool, len(alts))
+	for _, o := range old {
+		found := false
+		for i, a := range alts {
+			if !matched[i] && samewords(o.words, a.words) {
+				matched[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			removed = append(removed, o)
+		}
+	}
+	for i, a := range alts {
+		if !matched[i] {
+			added = append(added, a)
+		}
+	}
+
+	for len(removed) > 0 && len(added) > 0 {
+		diffline("changed rule " + diffrule(name, removed[0]))
+		diffline("          to " + diffrule(name, added[0]))
+		removed = removed[1:]
+		added = added[1:]
+	}
+	for _, a := range removed {
+		diffline("removed rule " + diffrule(name, a))
+	}
+	for _, a := range added {
+		diffline("added rule " + diffrule(name, a))
+	}
+}
+
+// the kind of a symbol, for the report
+func diffkind(terminal bool) string {
+	if terminal {
+		return "terminal"
+	}
+	return "nonterminal"
+}
+
+// bool diffgrammar()
+// report the differences between the remembered grammar and the global
+// grammar structure, returning whether there are any
+func diffgrammar() bool {
+	var s PSYMBOL
+	var old map[string]*diffsym
+	var name string
+	var newhead, newempty string
+
+	_diff.found = false
+	old = map[string]*diffsym{}
+	for i := range _diff.syms {
+		old[_diff.syms[i].name] = &_diff.syms[i]
+	}
+
+	if head != nil {
+		newhead = symname(head)
+	}
+	if newhead != _diff.head {
+		diffline("head " + diffnone(_diff.head) + " -> " + diffnone(newhead))
+	}
+	if emptypt != nil {
+		newempty = symname(emptypt)
+	}
+	if newempty != _diff.empty {
+		diffline("empty " + diffnone(_diff.empty) + " -> " + diffnone(newempty))
+	}
+
+	// symbols added, removed, or changing kind
+	for s = symlist; s != nil; s = s.next {
+		if diffignored(s) {
+			continue
+		}
+		name = symname(s)
+		if ds := old[name]; ds == nil {
+			diffline("added " + diffkind(TERMINAL(s)) + " " + name)
+		} else if ds.terminal != TERMINAL(s) {
+			diffline(name + " became " + diffkind(TERMINAL(s)))
+		}
+	}
+	for _, ds := range _diff.syms {
+		if s = lookupname(ds.name); s == nil || diffignored(s) {
+			diffline("removed " + diffkind(ds.terminal) + " " + ds.name)
+		}
+	}
+
+	// rules, by symbol
+	for s = symlist; s != nil; s = s.next {
+		if diffignored(s) {
+			continue
+		}
+		if ds := old[symname(s)]; ds != nil {
+			diffrules(s, ds.rules)
+		} else {
+			diffrules(s, nil)
+		}
+	}
+	for _, ds := range _diff.syms {
+		if s = lookupname(ds.name); s == nil || diffignored(s) {
+			for _, a := range ds.rules {
+				diffline("removed rule " + diffrule(ds.name, a))
+			}
+		}
+	}
+
+	// reachability, an added symbol being unreachable before
+	reachsetup()
+	if head != nil {
+		reachtouch(head)
+	}
+	for s = symlist; s != nil; s = s.next {
+		if diffignored(s) {
+			continue
+		}
+		ds := old[symname(s)]
+		if ds == nil {
+			if s.state == TOUCHED {
+				diffline("now reachable " + symname(s))
+			} else if head != nil {
+				diffline("not reachable " + symname(s))
+			}
+		} else if ds.reachable && s.state != TOUCHED {
+			diffline("no longer reachable " + ds.name)
+		} else if !ds.reachable && s.state == TOUCHED {
+			diffline("now reachable " + ds.name)
+		}
+	}
+	return _diff.found
+}
+
+// a symbol name for the report, or (none)
+func diffnone(name string) string {
+	if name == "" {
+		return "(none)"
+	}
+	return name
+}
@@ -0,0 +1,35 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"strings"
+	"testing"
+)
+
+// a rule with bars inside brackets is compared as one alternative, and an
+// empty alternative is shown with the empty symbol of its own grammar
+func TestDiffGrammar(t *testing.T) {
+	old := "> s\n/ e\ns ::= a ( b | e ) | c\n"
+	new := "> s\n/ eps\ns ::= a ( b | d | eps ) | c\n"
+	want := "empty e -> eps\n" +
+		"added terminal d\n" +
+		"changed rule s ::= a ( b | e )\n" +
+		"          to s ::= a ( b | d | eps )\n" +
+		"now reachable d\n"
+	got, errs := convert(old, ReadGrammar, func() {
+		RememberGrammar()
+		SetStdinReader(strings.NewReader(new))
+		ReadGrammar()
+		if !DiffGrammar() {
+			t.Errorf("no differences found")
+		}
+	})
+	if len(errs) != 0 {
+		t.Errorf("unexpected errors %v", errs)
+	}
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
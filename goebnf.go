@@ -0,0 +1,643 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Go EBNF grammars.
+// The Go specification and golang.org/x/exp/ebnf use this notation:
+//    |
+//    |Production  = name "=" [ Expression ] "." .
+//    |Expression  = Alternative { "|" Alternative } .
+//    |Alternative = Term { Term } .
+//    |Term        = name | token [ "…" token ] | Group | Option | Repetition .
+//    |Group       = "(" Expression ")" .
+//    |Option      = "[" Expression "]" .
+//    |Repetition  = "{" Expression "}" .
+//    |
+// readgoebnf reads a grammar in this notation from stdin into the global
+// grammar structure, keeping the brackets as gtools EBNF.  Tokens become
+// quoted terminals and a range "a" … "z" becomes the alternatives it
+// stands for.  The first production is the distinguished symbol unless
+// another is named.  An empty production uses '' as the empty symbol.
+//
+// writegoebnf writes the global grammar structure in this notation.
+// Lower case names are lexical productions in Go EBNF, which can only
+// refer to other lexical productions, so nonterminal names are made into
+// names and capitalized, unless they are already Go EBNF names that are
+// capitalized, or lower case ones for a lexical production, one that
+// isn't the distinguished symbol and refers to no production that isn't
+// lexical.  A capitalized name that is taken gets a number added.  Where gdeebnf invented a symbol for a bracketed group, and
+// it is used only once, the { } [ ] or ( ) it came from is put back in
+// place of the symbol; everything else is plain alternatives.
+
+func ReadGoEBNF(start string) {
+	readgoebnf(start)
+}
+
+func WriteGoEBNF() {
+	writegoebnf()
+}
+
+// largest range expanded into alternatives
+const MAXRANGE = 256
+
+// Go EBNF lexical analysis
+
+// kinds of Go EBNF token, punctuation is its own kind
+const (
+	GETEOF   = iota + 256 // end of file
+	GETNAME               // production name
+	GETTOKEN              // "token"
+	GETRANGE              // … or ...
+)
+
+type gtoken struct {
+	kind int    // kind of token
+	text string // text of token, the value of a "token"
+	line int    // source line number on which token starts
+	pos  span   // where token is
+}
+
+// global variables private to this package
+var _goebnf struct {
+	src  []byte   // the grammar being read
+	pos  int      // position of next unread character in src
+	line int      // current line number in src
+	toks []gtoken // tokens read from src
+	next int      // index of next unparsed token in toks
+}
+
+// break the grammar source up into tokens
+func glex() {
+	var tok gtoken
+	var start int
+	var r rune
+	var size int
+
+	src := _goebnf.src
+	for {
+		// skip white space and comments
+		for _goebnf.pos < len(src) {
+			c := src[_goebnf.pos]
+			if c == '\n' {
+				_goebnf.line++
+				_goebnf.pos++
+			} else if c == ' ' || c == '\t' || c == '\r' {
+				_goebnf.pos++
+			} else if strings.HasPrefix(string(src[_goebnf.pos:]), "//") {
+				for _goebnf.pos < len(src) && src[_goebnf.pos] != '\n' {
+					_goebnf.pos++
+				}
+			} else if strings.HasPrefix(string(src[_goebnf.pos:]), "/*") {
+				_goebnf.pos += 2
+				for _goebnf.pos < len(src) && !strings.HasPrefix(string(src[_goebnf.pos:]), "*/") {
+					if src[_goebnf.pos] == '\n' {
+						_goebnf.line++
+					}
+					_goebnf.pos++
+				}
+				_goebnf.pos += 2
+			} else {
+				break
+			}
+		}
+
+		tok.line = _goebnf.line
+		if _goebnf.pos >= len(src) {
+			tok.kind = GETEOF
+			tok.text = ""
+			_goebnf.toks = append(_goebnf.toks, tok)
+			return
+		}
+
+		start = _goebnf.pos
+		r, size = utf8.DecodeRune(src[_goebnf.pos:])
+		switch {
+		case unicode.IsLetter(r) || r == '_':
+			for _goebnf.pos < len(src) {
+				r, size = utf8.DecodeRune(src[_goebnf.pos:])
+				if !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') {
+					break
+				}
+				_goebnf.pos += size
+			}
+			tok.kind = GETNAME
+			tok.text = string(src[start:_goebnf.pos])
+		case r == '"' || r == '`':
+			_goebnf.pos++
+			for _goebnf.pos < len(src) && rune(src[_goebnf.pos]) != r && (r == '`' || src[_goebnf.pos] != '\n') {
+				if r == '"' && src[_goebnf.pos] == '\\' {
+					_goebnf.pos++
+				}
+				if src[_goebnf.pos] == '\n' {
+					_goebnf.line++
+				}
+				_goebnf.pos++
+			}
+			if _goebnf.pos < len(src) && rune(src[_goebnf.pos]) == r {
+				_goebnf.pos++
+			}
+			value, err := strconv.Unquote(string(src[start:_goebnf.pos]))
+			if err != nil {
+				errormsg("BAD TOKEN "+string(src[start:_goebnf.pos]), tok.line)
+				value = string(src[start+1 : _goebnf.pos])
+			}
+			tok.kind = GETTOKEN
+			tok.text = value
+		case r == '…':
+			_goebnf.pos += size
+			tok.kind = GETRANGE
+			tok.text = "…"
+		case strings.HasPrefix(string(src[_goebnf.pos:]), "..."):
+			_goebnf.pos += 3
+			tok.kind = GETRANGE
+			tok.text = "…"
+		default:
+			_goebnf.pos += size
+			tok.kind = int(r)
+			tok.text = string(r)
+		}
+		tok.pos = bytespan(src, tok.line, start, _goebnf.pos)
+		_goebnf.toks = append(_goebnf.toks, tok)
+	}
+}
+
+// Go EBNF parsing
+
+// the current token
+func gtok() *gtoken {
+	return &_goebnf.toks[_goebnf.next]
+}
+
+// the token before the current token
+func gprev() *gtoken {
+	if _goebnf.next == 0 {
+		return gtok()
+	}
+	return &_goebnf.toks[_goebnf.next-1]
+}
+
+// move on to the next token, never past the end of file
+func gadvance() {
+	if gtok().kind != GETEOF {
+		_goebnf.next++
+	}
+}
+
+// consume a token of the given kind if it is there
+func gaccept(kind int) bool {
+	if gtok().kind == kind {
+		gadvance()
+		return true
+	}
+	return false
+}
+
+// gtoolsquote turns the value of a token into a quoted terminal symbol,
+// using whichever quote mark the value doesn't contain
+func gtoolsquote(value string) string {
+	var printable bool
+
+	printable = true
+	for _, r := range value {
+		if !unicode.IsPrint(r) {
+			printable = false
+		}
+	}
+	if printable && !strings.Contains(value, "'") {
+		return "'" + value + "'"
+	} else if printable && !strings.Contains(value, `"`) {
+		return `"` + value + `"`
+	}
+	return strconv.Quote(value)
+}
+
+// get a reference to the terminal symbol for a token
+func gterminal(value string, line int, pos span) *gnode {
+	return &gnode{kind: GSYMBOL, sym: seen(namedsym(gtoolsquote(value)), pos), line: line, pos: pos}
+}
+
+// get an expression, up to ) ] } or .
+func gexpression() *gnode {
+	var t *gnode
+
+	t = &gnode{kind: GALT, line: gtok().line}
+	for {
+		t.kids = append(t.kids, galternative())
+		if !gaccept('|') {
+			break
+		}
+	}
+	return t
+}
+
+// get an alternative, a sequence of terms
+func galternative() *gnode {
+	var t *gnode
+	var tok *gtoken
+	var g *gnode
+
+	t = &gnode{kind: GSEQ, line: gtok().line}
+	for {
+		tok = gtok()
+		line = tok.line // for any symbols defined here
+		switch tok.kind {
+		case GETNAME:
+			t.kids = append(t.kids, &gnode{kind: GSYMBOL, sym: seen(namedsym(tok.text), tok.pos), line: tok.line, pos: tok.pos})
+			gadvance()
+		case GETTOKEN:
+			gadvance()
+			if gaccept(GETRANGE) {
+				hi := gtok()
+				if !gaccept(GETTOKEN) {
+					errorin("MISSING END OF RANGE", tok.line, gtok().pos)
+				}
+				t.kids = append(t.kids, grange(tok.text, hi.text, tok.line, spanto(tok.pos, gprev().pos)))
+			} else {
+				t.kids = append(t.kids, gterminal(tok.text, tok.line, tok.pos))
+			}
+		case '(', '[', '{':
+			gadvance()
+			g = gexpression()
+			switch tok.kind {
+			case '(':
+				g.kind = GALT
+			case '[':
+				g.kind = GOPT
+			case '{':
+				g.kind = GREP
+			}
+			if !gaccept(map[int]int{'(': ')', '[': ']', '{': '}'}[tok.kind]) {
+				errorin("MISSING "+metanames[g.kind][1], tok.line, gtok().pos)
+			}
+			g.pos = spanto(tok.pos, gprev().pos)
+			t.kids = append(t.kids, g)
+		case GETEOF: // the production is cut short, readgoebnf says so
+			return t
+		default:
+			if len(t.kids) == 0 && tok.kind != '.' && tok.kind != '|' {
+				errorin("UNEXPECTED "+tok.text, tok.line, tok.pos)
+				gadvance()
+				continue
+			}
+			return t
+		}
+	}
+}
+
+// expand the range lo … hi into alternatives
+func grange(lo, hi string, ln int, pos span) *gnode {
+	var t *gnode
+	var rlo, rhi rune
+
+	t = &gnode{kind: GALT, line: ln}
+	rlo, _ = utf8.DecodeRuneInString(lo)
+	rhi, _ = utf8.DecodeRuneInString(hi)
+	if utf8.RuneCountInString(lo) != 1 || utf8.RuneCountInString(hi) != 1 || rlo > rhi {
+		errorin("BAD RANGE", ln, pos)
+		t.kids = append(t.kids, &gnode{kind: GSEQ, kids: []*gnode{gterminal(lo, ln, pos)}, line: ln})
+		return t
+	}
+	if rhi-rlo >= MAXRANGE {
+		errorin("RANGE TOO LARGE", ln, pos)
+		rhi = rlo + MAXRANGE - 1
+	}
+	for r := rlo; r <= rhi; r++ {
+		t.kids = append(t.kids, &gnode{kind: GSEQ, kids: []*gnode{gterminal(string(r), ln, pos)}, line: ln})
+	}
+	return t
+}
+
+// readgoebnf: read Go EBNF grammar into global grammar structure in grammar.h
+func readgoebnf(start string) {
+	var s PSYMBOL
+	var t *gnode
+	var tok *gtoken
+
+	newgrammar()
+	_goebnf.src = readall()
+	_goebnf.pos = 0
+	_goebnf.line = 1
+	_goebnf.toks = nil
+	_goebnf.next = 0
+	glex()
+
+	for gtok().kind != GETEOF {
+		tok = gtok()
+		if tok.kind != GETNAME {
+			errorin("EXPECTED PRODUCTION NAME", tok.line, tok.pos)
+			for gtok().kind != '.' && gtok().kind != GETEOF {
+				gadvance()
+			}
+			gaccept('.')
+			continue
+		}
+		line = tok.line
+		s = seen(namedsym(tok.text), tok.pos)
+		gadvance()
+
+		if !gaccept('=') {
+			errorin("MISSING =", tok.line, gtok().pos)
+		}
+		if gtok().kind == '.' {
+			t = &gnode{kind: GSEQ, line: tok.line}
+		} else {
+			t = gexpression()
+		}
+		if !gaccept('.') {
+			errorin("MISSING . AT END OF PRODUCTION", gtok().line, gtok().pos)
+			for gtok().kind != '.' && gtok().kind != GETEOF {
+				gadvance()
+			}
+			gaccept('.')
+		}
+
+		if s.data != nil {
+			errorin("PRODUCTION DEFINED TWICE", tok.line, tok.pos)
+		}
+		treetorules(s, t)
+		if head == nil && start == "" {
+			head = s
+		}
+	}
+
+	if start != "" {
+		head = lookupname(start)
+		if head == nil {
+			errormsg("NO PRODUCTION NAMED "+start, -1)
+		}
+	}
+	if head == nil {
+		errormsg("NO PRODUCTIONS", -1)
+	}
+	line = -1 // mark any new line numbers as fictional
+}
+
+// Go EBNF output
+
+// global variables private to this package
+var _goebnfout struct {
+	names   map[PSYMBOL]string // Go EBNF text for each symbol
+	lexical []string           // lexical productions that are not defined
+	contcol int                // column number for continuation
+}
+
+// decide on the Go EBNF text for every symbol
+func goebnfnames() {
+	var s PSYMBOL
+	var used map[string]bool
+	var name, id, body string
+
+	_goebnfout.names = map[PSYMBOL]string{}
+	_goebnfout.lexical = nil
+	used = map[string]bool{}
+
+	lexical := goebnflexical()
+	for s = symlist; s != nil; s = s.next {
+		if NONTERMINAL(s) && isgoname(symname(s)) && (lexical[s] || !islexical(symname(s))) {
+			_goebnfout.names[s] = uniquename(symname(s), used)
+		}
+	}
+	for s = symlist; s != nil; s = s.next {
+		if _, named := _goebnfout.names[s]; NONTERMINAL(s) && !named {
+			id = identifier(symname(s))
+			if id == "" || !('a' <= id[0] && id[0] <= 'z' || 'A' <= id[0] && id[0] <= 'Z') {
+				id = "P" + id
+			}
+			_goebnfout.names[s] = uniquename(strings.ToUpper(id[:1])+id[1:], used)
+		}
+	}
+
+	for s = symlist; s != nil; s = s.next {
+		if NONTERMINAL(s) {
+			continue
+		}
+		name = symname(s)
+		if s == emptypt {
+			_goebnfout.names[s] = ""
+		} else if isquoted(name) {
+			body = name[1 : len(name)-1]
+			if _, err := strconv.Unquote(`"` + body + `"`); err == nil && name[0] == '"' && strings.Contains(body, `\`) {
+				_goebnfout.names[s] = name // already a Go string
+			} else {
+				_goebnfout.names[s] = strconv.Quote(body)
+			}
+		} else if id = identifier(name); id != "" {
+			if !('a' <= id[0] && id[0] <= 'z' || 'A' <= id[0] && id[0] <= 'Z') {
+				id = "t" + id
+			}
+			id = uniquename(strings.ToLower(id[:1])+id[1:], used)
+			_goebnfout.names[s] = id
+			_goebnfout.lexical = append(_goebnfout.lexical, id)
+		} else {
+			_goebnfout.names[s] = strconv.Quote(name)
+		}
+	}
+}
+
+// goebnflexical finds the nonterminals that can stay lexical productions:
+// those with lexical Go EBNF names that are not the distinguished
+// symbol, and don't refer to any that aren't lexical, since a lexical
+// production can't refer to one that isn't
+func goebnflexical() map[PSYMBOL]bool {
+	var lexical = map[PSYMBOL]bool{}
+	var s PSYMBOL
+	var p PPRODUCTION
+	var e PELEMENT
+	var changed bool
+
+	for s = symlist; s != nil; s = s.next {
+		if NONTERMINAL(s) && s != head && isgoname(symname(s)) && islexical(symname(s)) {
+			lexical[s] = true
+		}
+	}
+	for changed = true; changed; {
+		changed = false
+		for s = symlist; s != nil; s = s.next {
+			if !lexical[s] {
+				continue
+			}
+			for p = s.data; p != nil && lexical[s]; p = p.next {
+				for e = p.data; e != nil; e = e.next {
+					if NONTERMINAL(e.data) && !lexical[e.data] {
+						lexical[s] = false
+						changed = true
+						break
+					}
+				}
+			}
+		}
+	}
+	return lexical
+}
+
+// islexical reports whether a Go EBNF name is that of a lexical
+// production, as it is unless it starts with an upper case letter
+func islexical(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	return !unicode.IsUpper(r)
+}
+
+// isgoname reports whether a symbol name is already a Go EBNF name
+func isgoname(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if !(unicode.IsLetter(r) || r == '_' || i > 0 && unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// put out one Go EBNF word, wrapping long lines
+func goword(w string) {
+	outspacelen(strwidth(w), _goebnfout.contcol, ' ')
+	outstr(w)
+}
+
+// nonempty returns the alternatives of t that are not empty
+func nonempty(t *gnode) []*gnode {
+	var alts []*gnode
+
+	for _, a := range t.kids {
+		if len(a.kids) != 0 {
+			alts = append(alts, a)
+		}
+	}
+	return alts
+}
+
+// put out the alternatives alts, separated by bars
+func goalts(alts []*gnode) {
+	for i, a := range alts {
+		if i > 0 {
+			goword("|")
+		}
+		goexpr(a)
+	}
+}
+
+// put out the tree t in Go EBNF notation
+func goexpr(t *gnode) {
+	var alts []*gnode
+
+	switch t.kind {
+	case GSYMBOL:
+		if name := _goebnfout.names[t.sym]; name != "" {
+			goword(name)
+		}
+	case GSEQ:
+		for _, k := range t.kids {
+			goexpr(k)
+		}
+	case GALT:
+		alts = nonempty(t)
+		if len(alts) == 0 {
+			return
+		} else if len(alts) != len(t.kids) { // an empty alternative
+			goword("[")
+			goalts(alts)
+			goword("]")
+		} else if len(alts) == 1 {
+			goexpr(alts[0])
+		} else {
+			goword("(")
+			goalts(alts)
+			goword(")")
+		}
+	case GOPT, GREP:
+		alts = nonempty(t)
+		if len(alts) == 0 {
+			return
+		}
+		goword(metanames[t.kind][0])
+		goalts(alts)
+		goword(metanames[t.kind][1])
+	}
+}
+
+// writegoebnf
+// write grammar structure in the notation of the Go specification
+func writegoebnf() {
+	var order []PSYMBOL
+	var trees map[PSYMBOL]*gnode
+	var absorbed map[PSYMBOL]bool
+	var count map[PSYMBOL]int
+	var alts []*gnode
+	var barcol int
+
+	outsetup()
+	goebnfnames()
+
+	// put the brackets back before writing anything
+	order = reachorder()
+	count = uses()
+	trees = map[PSYMBOL]*gnode{}
+	absorbed = map[PSYMBOL]bool{}
+	for _, s := range order {
+		if !absorbed[s] {
+			trees[s] = rulestotree(s)
+			rebracket(trees[s], s, count, absorbed)
+		}
+	}
+
+	if head != nil {
+		outstr("// start: ")
+		outstr(_goebnfout.names[head])
+		outline()
+	}
+	for _, s := range order {
+		if absorbed[s] {
+			continue
+		}
+		outline()
+		outstr(_goebnfout.names[s])
+		outchar(' ')
+		barcol = getoutcol()
+		outchar('=')
+		_goebnfout.contcol = barcol + 2
+
+		alts = nonempty(trees[s])
+		if len(alts) != 0 && len(alts) != len(trees[s].kids) {
+			// an empty alternative, Go EBNF has no way to say that
+			// except by making the whole expression optional
+			goword("[")
+			goalts(alts)
+			goword("]")
+		} else {
+			for i, a := range alts {
+				if i > 0 {
+					outline()
+					outspaces(barcol)
+					outchar('|')
+				}
+				goexpr(a)
+			}
+		}
+		outstr(" .")
+	}
+	outline()
+
+	if len(_goebnfout.lexical) != 0 {
+		outline()
+		outstr("// lexical productions defined elsewhere:")
+		for _, t := range _goebnfout.lexical {
+			if getoutcol()+1+len(t) > 80 {
+				outline()
+				outstr("//")
+			}
+			outchar(' ')
+			outstr(t)
+		}
+		outline()
+	}
+}
@@ -0,0 +1,120 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// a production cut short by the end of the file is reported, and reading
+// stops there rather than waiting for the rest of it
+func TestGoEBNFTruncated(t *testing.T) {
+	var diags []Diagnostic
+
+	SetStdout(io.Discard)
+	defer SetStdout(os.Stdout)
+	SetDiagnostics(func(d Diagnostic) {
+		diags = append(diags, d)
+	})
+	defer SetDiagnostics(nil)
+
+	for _, src := range []string{"a", "a =", "a = \"x\" |", "a = ( \"x\"", "a = \"x\" … "} {
+		diags = nil
+		SetStdinReader(strings.NewReader(src))
+		done := make(chan bool)
+		go func() {
+			ReadGoEBNF("")
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%q: reading did not stop at the end of the file", src)
+		}
+		found := false
+		for _, d := range diags {
+			if d.Message == "MISSING . AT END OF PRODUCTION" {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%q: the missing . was not reported, got %v", src, diags)
+		}
+	}
+}
+
+// a Go EBNF grammar read and written back is the same grammar, lexical
+// productions staying lexical
+func TestGoEBNFRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"lexical",
+			"Number = digit { digit | \"_\" } .\n" +
+				"digit = \"0\" … \"2\" .\n",
+			"// start: Number\n" +
+				"\n" +
+				"Number = digit { digit | \"_\" } .\n" +
+				"digit = ( \"0\" | \"1\" | \"2\" ) .\n"},
+		{"brackets",
+			"Expr = Term { ( \"+\" | \"-\" ) Term } .\n" +
+				"Term = [ \"-\" ] ident .\n",
+			"// start: Expr\n" +
+				"\n" +
+				"Expr = Term { ( \"+\" | \"-\" ) Term } .\n" +
+				"Term = [ \"-\" ] ident .\n" +
+				"\n" +
+				"// lexical productions defined elsewhere: ident\n"},
+		{"empty",
+			"List = Item List | .\n" +
+				"Item = \"x\" .\n",
+			"// start: List\n" +
+				"\n" +
+				"List = [ Item List ] .\n" +
+				"Item = \"x\" .\n"},
+	} {
+		got, errs := convert(tc.src, func() { ReadGoEBNF("") }, WriteGoEBNF)
+		if len(errs) != 0 {
+			t.Errorf("%s: unexpected errors %v", tc.name, errs)
+		}
+		if got != tc.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tc.name, got, tc.want)
+		}
+	}
+}
+
+// nonterminals are capitalized, unless they can stay lexical productions,
+// which refer to no others
+func TestGoEBNFNames(t *testing.T) {
+	src := "> expr\n" +
+		"expr ::= Expr | digits\n" +
+		"Expr ::= x\n" +
+		"digits ::= digit { digit }\n" +
+		"digit ::= \"0\" | \"1\"\n" +
+		"_x ::= expr\n"
+	want := "// start: Expr_2\n" +
+		"\n" +
+		"Expr_2 = Expr\n" +
+		"       | digits .\n" +
+		"Expr = x .\n" +
+		"digits = digit { digit } .\n" +
+		"digit = \"0\"\n" +
+		"      | \"1\" .\n" +
+		"P_x = Expr_2 .\n" +
+		"\n" +
+		"// lexical productions defined elsewhere: x\n"
+	got, errs := convert(src, ReadGrammar, WriteGoEBNF)
+	if len(errs) != 0 {
+		t.Errorf("unexpected errors %v", errs)
+	}
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -28,7 +28,8 @@ const COMMENT = '#'
 // #define RULESYM "::="
 var RULESYM = "::="
 
-// note:  RULESYM is for writeg, while readg accepts any of :, =, := or ::=
+// note:  RULESYM is the default for writeg, see Format, while readg accepts
+// any of :, =, := or ::=
 
 // Types
 
@@ -53,6 +54,18 @@ type PPRODUCTION *production
 
 type PELEMENT *element
 
+// Where something is in the source: the file, or "" for standard input,
+// and the line and column it starts on and those just past its end.
+// Lines and columns count from 1, columns by character, not by byte.
+// A span with line 0 is nowhere, as for things made up by the tools.
+type span struct {
+	file    string
+	line    int
+	col     int
+	endline int
+	endcol  int
+}
+
 type symbol struct {
 	name    STRINGPT    // symbols have names
 	next    PSYMBOL     // symbols may occur in lists of symbols
@@ -60,7 +73,10 @@ type symbol struct {
 	state   STYPE       // the state of this symbol
 	starter PELEMENT    // the head of the terminal list in the start set
 	follows PELEMENT    // the head of the terminal list in the follow set
+	example []PSYMBOL   // the shortest string of terminals it derives
 	line    int         // source line number on which symbol first seen
+	pos     span        // where symbol first seen, or the group it replaced
+	comment string      // description from comments around its rules
 }
 
 type production struct {
@@ -70,12 +86,18 @@ type production struct {
 	starter PELEMENT    // the head of the terminal list in the start set
 	ender   PELEMENT    // the head of the terminal list in the follow set
 	line    int         // source line number on which production starts
+	pos     span        // where production is, from first to last element
+	from    []span      // where the source rules it was made from are
+	lhs     span        // where its symbol was given at the left of the rule
+	comment string      // source lines of comments and blanks before it
+	weight  float64     // how often sample picks it, against the others
 }
 
 type element struct {
 	next PELEMENT // each production is a list of elements
 	data PSYMBOL  // an element is a handle on a symbol, never NULL
 	line int      // source line number on which element occurs
+	pos  span     // where element is
 }
 
 // storage allocators
@@ -85,7 +107,7 @@ func NEWSYMBOL() PSYMBOL {
 }
 
 func NEWPRODUCTION() PPRODUCTION {
-	return &production{}
+	return &production{weight: 1}
 }
 
 func NEWELEMENT() PELEMENT {
@@ -121,3 +143,14 @@ var emptypt PSYMBOL
 
 // Identity of the distinguished symbol in the grammar
 var head PSYMBOL
+
+// Description of the grammar, from comments before its first rule
+var description string
+
+// Comments and blank lines kept from the source so writeg can put them back:
+// those before the metarules, those after the last rule, and the text
+// following the symbol on the distinguished and empty symbol metarules
+var preamble string
+var trailer string
+var headnote string
+var emptynote string
@@ -0,0 +1,510 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// JSON serialisation of the grammar model.
+// The global grammar structure is written and read as one JSON object:
+//    |
+//    |{
+//    |  "schema": "gtools-grammar/1",
+//    |  "head": "<expression>",
+//    |  "empty": "''",
+//    |  "symbols": [
+//    |    {
+//    |      "name": "<expression>",
+//    |      "kind": "nonterminal",
+//    |      "line": 3,
+//    |      "span": { "line": 3, "col": 1, "endline": 3, "endcol": 13 },
+//    |      "productions": [
+//    |        { "line": 3, "span": { ... }, "elements": [
+//    |          { "symbol": "<term>", "line": 3, "span": { ... } }
+//    |        ] }
+//    |      ],
+//    |      "start": [ "-", "<number>" ],
+//    |      "follow": [ "+", ")" ]
+//    |    }
+//    |  ]
+//    |}
+//    |
+// Symbols are listed in the order they were first seen and refer to each
+// other by name.  Comments kept from the source describe the grammar and
+// its symbols, and the comment lines as they stood are kept too, with the
+// production they came before and around the metarules.  Spans give
+// where things are in the source, as in span.go, with the file left out
+// for standard input; a production made from others by the tools lists
+// where they are under "from", and one weighted for sample has its weight
+// under "weight".  Head and empty are omitted when the grammar has none,
+// start and follow when they have not been computed, and spans when
+// things are nowhere in the source.  The same schema can be rendered as
+// YAML, which is written but not read.  A symbol name that readg couldn't
+// read back, such as "" or one holding blanks outside quotes, is reported
+// at its place in the JSON, as symbols[2].name, and left out, as is a
+// comment that isn't blank lines and # lines each ending in a newline, or
+// for headnote and emptynote, the rest of one line after a blank.
+
+// the schema written, and the only one read
+const JSONSCHEMA = "gtools-grammar/1"
+
+type jsongrammar struct {
+	Schema      string       `json:"schema"`
+	Head        string       `json:"head,omitempty"`
+	Empty       string       `json:"empty,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Preamble    string       `json:"preamble,omitempty"`
+	Headnote    string       `json:"headnote,omitempty"`
+	Emptynote   string       `json:"emptynote,omitempty"`
+	Trailer     string       `json:"trailer,omitempty"`
+	Symbols     []jsonsymbol `json:"symbols"`
+}
+
+type jsonsymbol struct {
+	Name        string           `json:"name"`
+	Kind        string           `json:"kind"` // terminal or nonterminal
+	Line        int              `json:"line"`
+	Span        *jsonspan        `json:"span,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Productions []jsonproduction `json:"productions,omitempty"`
+	Start       []string         `json:"start,omitempty"`
+	Follow      []string         `json:"follow,omitempty"`
+}
+
+type jsonproduction struct {
+	Line     int           `json:"line"`
+	Span     *jsonspan     `json:"span,omitempty"`
+	From     []jsonspan    `json:"from,omitempty"`
+	Comment  string        `json:"comment,omitempty"`
+	Weight   *float64      `json:"weight,omitempty"`
+	Elements []jsonelement `json:"elements"`
+}
+
+type jsonelement struct {
+	Symbol string    `json:"symbol"`
+	Line   int       `json:"line"`
+	Span   *jsonspan `json:"span,omitempty"`
+}
+
+type jsonspan struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line"`
+	Col     int    `json:"col"`
+	Endline int    `json:"endline"`
+	Endcol  int    `json:"endcol"`
+}
+
+// the JSON form of sp, nil if it is nowhere
+func tojsonspan(sp span) *jsonspan {
+	if sp.line == 0 {
+		return nil
+	}
+	return &jsonspan{File: sp.file, Line: sp.line, Col: sp.col, Endline: sp.endline, Endcol: sp.endcol}
+}
+
+// the span that js stands for
+func fromjsonspan(js *jsonspan) span {
+	if js == nil {
+		return span{}
+	}
+	return span{file: js.File, line: js.Line, col: js.Col, endline: js.Endline, endcol: js.Endcol}
+}
+
+func WriteJSON() {
+	writejson()
+}
+
+func ReadJSON() {
+	readjson()
+}
+
+func WriteYAML() {
+	writeyaml()
+}
+
+// names of the symbols in the list of elements e
+func jsonnames(e PELEMENT) []string {
+	var names []string
+
+	for ; e != nil; e = e.next {
+		names = append(names, symname(e.data))
+	}
+	return names
+}
+
+// build the JSON form of the global grammar structure
+func tojson() *jsongrammar {
+	var g jsongrammar
+	var s PSYMBOL
+	var p PPRODUCTION
+	var e PELEMENT
+
+	g.Schema = JSONSCHEMA
+	if head != nil {
+		g.Head = symname(head)
+	}
+	if emptypt != nil {
+		g.Empty = symname(emptypt)
+	}
+	g.Description = description
+	g.Preamble = preamble
+	g.Headnote = headnote
+	g.Emptynote = emptynote
+	g.Trailer = trailer
+	g.Symbols = []jsonsymbol{}
+	for s = symlist; s != nil; s = s.next {
+		js := jsonsymbol{Name: symname(s), Kind: "terminal", Line: s.line, Span: tojsonspan(s.pos), Description: s.comment}
+		if NONTERMINAL(s) {
+			js.Kind = "nonterminal"
+		}
+		for p = s.data; p != nil; p = p.next {
+			jp := jsonproduction{Line: p.line, Span: tojsonspan(p.pos), Comment: p.comment, Elements: []jsonelement{}}
+			if p.weight != 1 {
+				jp.Weight = &p.weight
+			}
+			for _, sp := range p.from {
+				if from := tojsonspan(sp); from != nil {
+					jp.From = append(jp.From, *from)
+				}
+			}
+			for e = p.data; e != nil; e = e.next {
+				jp.Elements = append(jp.Elements, jsonelement{Symbol: symname(e.data), Line: e.line, Span: tojsonspan(e.pos)})
+			}
+			js.Productions = append(js.Productions, jp)
+		}
+		js.Start = jsonnames(s.starter)
+		js.Follow = jsonnames(s.follows)
+		g.Symbols = append(g.Symbols, js)
+	}
+	return &g
+}
+
+// writejson
+// write grammar structure as JSON
+func writejson() {
+	fputs(jsonencode(tojson(), "  "), stdout)
+}
+
+// encode v as JSON, leaving < > & alone since grammars are full of them
+func jsonencode(v any, indent string) string {
+	var sb strings.Builder
+
+	enc := json.NewEncoder(&sb)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(v); err != nil {
+		panic(err)
+	}
+	return sb.String()
+}
+
+// badname reports whether readg couldn't read name back as one symbol:
+// it is empty, holds layout outside quotes or brackets, or is the rule
+// symbol, which would be taken for the start of a rule
+func badname(name string) bool {
+	if name == "" || name == RULESYM || strings.ContainsAny(name, "\n\r") {
+		return true
+	}
+	if isquoted(name) || isbracketed(name) {
+		return false
+	}
+	return strings.ContainsAny(name, " \t")
+}
+
+// check that a name given at path in the JSON is a symbol name,
+// reporting it if not
+func jsonname(name string, ln int, path string) bool {
+	if badname(name) {
+		errormsg("BAD SYMBOL NAME "+strconv.Quote(name)+" AT "+path, ln)
+		return false
+	}
+	return true
+}
+
+// badlayout reports whether readg couldn't read text back as the source
+// lines it stands for: each must be blank or a comment, and end in a newline
+func badlayout(text string) bool {
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		return true
+	}
+	for _, ln := range strings.SplitAfter(text, "\n") {
+		if ln != "\n" && ln != "" && ln[0] != COMMENT {
+			return true
+		}
+	}
+	return false
+}
+
+// badnote reports whether readg couldn't read note back as the rest of a
+// metarule line: it must be one line, set off from the symbol by a blank
+func badnote(note string) bool {
+	return strings.ContainsAny(note, "\n\r") || (note != "" && note[0] != ' ' && note[0] != '\t')
+}
+
+// check that text given at path in the JSON is layout, as bad says,
+// reporting it and giving none if not
+func jsonlayout(text string, bad func(string) bool, ln int, path string) string {
+	if bad(text) {
+		errormsg("BAD COMMENT "+strconv.Quote(text)+" AT "+path, ln)
+		return ""
+	}
+	return text
+}
+
+// look up a symbol named at path in the JSON, defining it if need be,
+// nil if the name is not a symbol name
+func jsonsym(name string, ln int, path string) PSYMBOL {
+	var s PSYMBOL
+
+	if !jsonname(name, ln, path) {
+		return nil
+	}
+	s = lookupname(name)
+	if s == nil {
+		errormsg("UNDECLARED SYMBOL "+name, ln)
+		line = ln
+		s = namedsym(name)
+	}
+	return s
+}
+
+// turn a list of names at path into a list of elements
+func jsonelements(names []string, ln int, path string) PELEMENT {
+	var eh PELEMENT
+	var pe *PELEMENT
+
+	pe = &eh
+	for i, name := range names {
+		s := jsonsym(name, ln, path+"["+itoa(i)+"]")
+		if s == nil {
+			continue
+		}
+		*pe = NEWELEMENT()
+		(*pe).data = s
+		pe = &((*pe).next)
+	}
+	return eh
+}
+
+// readjson: read JSON grammar into global grammar structure in grammar.h
+func readjson() {
+	var g jsongrammar
+	var s PSYMBOL
+	var pp *PPRODUCTION
+	var pe *PELEMENT
+
+	newgrammar()
+	if err := json.Unmarshal(readall(), &g); err != nil {
+		errormsg("BAD JSON: "+strings.ToUpper(err.Error()), -1)
+		line = -1
+		return
+	}
+	if g.Schema != JSONSCHEMA {
+		errormsg("UNKNOWN SCHEMA "+strconv.Quote(g.Schema), -1)
+	}
+
+	// define all the symbols first, so references can be forward
+	for i, js := range g.Symbols {
+		line = js.Line
+		if !jsonname(js.Name, js.Line, "symbols["+itoa(i)+"].name") {
+			continue
+		}
+		if lookupname(js.Name) != nil {
+			errormsg("SYMBOL LISTED TWICE "+js.Name, js.Line)
+			continue
+		}
+		namedsym(js.Name).pos = fromjsonspan(js.Span)
+	}
+
+	for i, js := range g.Symbols {
+		at := "symbols[" + itoa(i) + "]"
+		if badname(js.Name) {
+			continue
+		}
+		s = lookupname(js.Name)
+		pp = &(s.data)
+		for *pp != nil {
+			pp = &((*pp).next)
+		}
+		for j, jp := range js.Productions {
+			p := NEWPRODUCTION()
+			p.line = jp.Line
+			p.pos = fromjsonspan(jp.Span)
+			for _, sp := range jp.From {
+				p.from = append(p.from, fromjsonspan(&sp))
+			}
+			p.comment = jsonlayout(jp.Comment, badlayout, jp.Line, at+".productions["+itoa(j)+"].comment")
+			if jp.Weight != nil {
+				p.weight = *jp.Weight
+			}
+			p.state = UNTOUCHED
+			pe = &(p.data)
+			for k, je := range jp.Elements {
+				es := jsonsym(je.Symbol, je.Line, at+".productions["+itoa(j)+"].elements["+itoa(k)+"].symbol")
+				if es == nil {
+					continue
+				}
+				*pe = NEWELEMENT()
+				(*pe).line = je.Line
+				(*pe).pos = fromjsonspan(je.Span)
+				(*pe).data = es
+				pe = &((*pe).next)
+			}
+			if p.data == nil {
+				errormsg("EMPTY PRODUCTION RULE", jp.Line)
+			}
+			*pp = p
+			pp = &(p.next)
+		}
+		if (js.Kind == "nonterminal") != NONTERMINAL(s) {
+			errormsg("SYMBOL "+js.Name+" IS NOT "+strings.ToUpper(js.Kind), js.Line)
+		}
+		s.comment = js.Description
+		s.starter = jsonelements(js.Start, js.Line, at+".start")
+		s.follows = jsonelements(js.Follow, js.Line, at+".follow")
+	}
+
+	description = g.Description
+	preamble = jsonlayout(g.Preamble, badlayout, -1, "preamble")
+	headnote = jsonlayout(g.Headnote, badnote, -1, "headnote")
+	emptynote = jsonlayout(g.Emptynote, badnote, -1, "emptynote")
+	trailer = jsonlayout(g.Trailer, badlayout, -1, "trailer")
+	if g.Head != "" {
+		head = jsonsym(g.Head, -1, "head")
+	}
+	if g.Empty != "" {
+		emptypt = jsonsym(g.Empty, -1, "empty")
+	}
+	if head == nil {
+		errormsg("DISTINGUISHED SYMBOL NOT GIVEN", -1)
+	} else if TERMINAL(head) {
+		errormsg("DISTINGUISHED SYMBOL IS TERMINAL", head.line)
+	}
+	if (emptypt != nil) && (NONTERMINAL(emptypt)) {
+		errormsg("EMPTY SYMBOL IS NONTERMINAL", emptypt.data.line)
+	}
+	line = -1 // mark any new line numbers as fictional
+}
+
+// YAML rendering
+
+// put out a YAML scalar, every string is double quoted which YAML
+// reads the same way as a JSON string
+func yamlstr(s string) {
+	fputs(strings.TrimSuffix(jsonencode(s, ""), "\n"), stdout)
+}
+
+// put out a YAML string field, unless it is empty
+func yamlfield(indent string, key string, value string) {
+	if value == "" {
+		return
+	}
+	fputs(indent+key+": ", stdout)
+	yamlstr(value)
+	fputs("\n", stdout)
+}
+
+// a JSON object is also a YAML flow mapping
+func yamlflow(v any) string {
+	return strings.TrimSuffix(jsonencode(v, ""), "\n")
+}
+
+// put out a YAML span field, unless it is nowhere
+func yamlspan(indent string, key string, js *jsonspan) {
+	if js == nil {
+		return
+	}
+	fputs(indent+key+": "+yamlflow(js)+"\n", stdout)
+}
+
+// put out a YAML flow sequence of strings
+func yamllist(key string, names []string) {
+	if len(names) == 0 {
+		return
+	}
+	fputs("    "+key+": [", stdout)
+	for i, name := range names {
+		if i > 0 {
+			fputs(", ", stdout)
+		}
+		yamlstr(name)
+	}
+	fputs("]\n", stdout)
+}
+
+// writeyaml
+// write grammar structure as YAML, in the same schema as writejson
+func writeyaml() {
+	var g *jsongrammar
+
+	g = tojson()
+	fputs("schema: ", stdout)
+	yamlstr(g.Schema)
+	fputs("\n", stdout)
+	if g.Head != "" {
+		fputs("head: ", stdout)
+		yamlstr(g.Head)
+		fputs("\n", stdout)
+	}
+	if g.Empty != "" {
+		fputs("empty: ", stdout)
+		yamlstr(g.Empty)
+		fputs("\n", stdout)
+	}
+	yamlfield("", "description", g.Description)
+	yamlfield("", "preamble", g.Preamble)
+	yamlfield("", "headnote", g.Headnote)
+	yamlfield("", "emptynote", g.Emptynote)
+	yamlfield("", "trailer", g.Trailer)
+	if len(g.Symbols) == 0 {
+		fputs("symbols: []\n", stdout)
+		return
+	}
+	fputs("symbols:\n", stdout)
+	for _, js := range g.Symbols {
+		fputs("  - name: ", stdout)
+		yamlstr(js.Name)
+		fputs("\n", stdout)
+		fprintf(stdout, "    kind: %s\n", js.Kind)
+		fprintf(stdout, "    line: %d\n", js.Line)
+		yamlspan("    ", "span", js.Span)
+		yamlfield("    ", "description", js.Description)
+		if len(js.Productions) != 0 {
+			fputs("    productions:\n", stdout)
+			for _, jp := range js.Productions {
+				fprintf(stdout, "      - line: %d\n", jp.Line)
+				yamlspan("        ", "span", jp.Span)
+				if len(jp.From) != 0 {
+					fputs("        from:\n", stdout)
+					for _, sp := range jp.From {
+						fputs("          - "+yamlflow(&sp)+"\n", stdout)
+					}
+				}
+				yamlfield("        ", "comment", jp.Comment)
+				if jp.Weight != nil {
+					fputs("        weight: "+strconv.FormatFloat(*jp.Weight, 'g', -1, 64)+"\n", stdout)
+				}
+				if len(jp.Elements) == 0 {
+					fputs("        elements: []\n", stdout)
+					continue
+				}
+				fputs("        elements:\n", stdout)
+				for _, je := range jp.Elements {
+					fputs("          - {symbol: ", stdout)
+					yamlstr(je.Symbol)
+					fprintf(stdout, ", line: %d", je.Line)
+					if je.Span != nil {
+						fputs(", span: "+yamlflow(je.Span), stdout)
+					}
+					fputs("}\n", stdout)
+				}
+			}
+		}
+		yamllist("start", js.Start)
+		yamllist("follow", js.Follow)
+	}
+}
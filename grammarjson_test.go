@@ -0,0 +1,136 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// a grammar read from JSON is written as the grammar it describes
+func TestJSONRead(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"rules",
+			`{"schema": "gtools-grammar/1", "head": "<s>", "empty": "''", "symbols": [
+				{"name": "<s>", "kind": "nonterminal", "line": 1, "productions": [
+					{"line": 1, "elements": [{"symbol": "'a'", "line": 1}, {"symbol": "<s>", "line": 1}]},
+					{"line": 2, "elements": [{"symbol": "''", "line": 2}]}]},
+				{"name": "'a'", "kind": "terminal", "line": 1},
+				{"name": "''", "kind": "terminal", "line": 2}]}`,
+			"> <s>\n" +
+				"/ ''\n" +
+				"\n" +
+				"<s> ::= 'a' <s>\n" +
+				"     |  ''\n" +
+				"\n" +
+				"# terminals:   'a' ''\n"},
+		{"weights",
+			`{"schema": "gtools-grammar/1", "head": "s", "symbols": [
+				{"name": "s", "kind": "nonterminal", "line": 1, "productions": [
+					{"line": 1, "weight": 3, "elements": [{"symbol": "x", "line": 1}]},
+					{"line": 2, "elements": [{"symbol": "y", "line": 2}]}]},
+				{"name": "x", "kind": "terminal", "line": 1},
+				{"name": "y", "kind": "terminal", "line": 2}]}`,
+			"> s\n" +
+				"\n" +
+				"s ::= x @weight=3\n" +
+				"   |  y\n" +
+				"\n" +
+				"# terminals:   x y\n"},
+	} {
+		got, errs := convert(tc.src, ReadJSON, WriteGrammar)
+		if len(errs) != 0 {
+			t.Errorf("%s: unexpected errors %v", tc.name, errs)
+		}
+		if got != tc.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tc.name, got, tc.want)
+		}
+	}
+}
+
+// gtojson | gfromjson writes each example grammar as gcopy does
+func TestJSONRoundTrip(t *testing.T) {
+	names, err := filepath.Glob(filepath.Join("grammars", "*.gr"))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(names) == 0 {
+		t.Fatal("no grammars found")
+	}
+	for _, name := range names {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		copied, _ := convert(string(src), ReadGrammar, WriteGrammar)
+		json, _ := convert(string(src), ReadGrammar, WriteJSON)
+		got, errs := convert(json, ReadJSON, WriteGrammar)
+		if len(errs) != 0 && filepath.Base(name) != "errors.gr" {
+			t.Errorf("%s: unexpected errors %v", name, errs)
+		}
+		if got != copied {
+			t.Errorf("%s: got\n%s\nwant\n%s", name, got, copied)
+		}
+	}
+}
+
+// a name readg couldn't read back as a symbol is reported at its path
+func TestJSONBadNames(t *testing.T) {
+	src := `{"schema": "gtools-grammar/1", "head": "s", "symbols": [
+		{"name": "s", "kind": "nonterminal", "line": 1, "productions": [
+			{"line": 1, "elements": [{"symbol": "x", "line": 1}, {"symbol": "::=", "line": 1}]}]},
+		{"name": "", "kind": "terminal", "line": 2},
+		{"name": "a b", "kind": "terminal", "line": 3},
+		{"name": "'a b'", "kind": "terminal", "line": 4},
+		{"name": "x", "kind": "terminal", "line": 1}]}`
+	want := []string{
+		`BAD SYMBOL NAME "" AT symbols[1].name`,
+		`BAD SYMBOL NAME "a b" AT symbols[2].name`,
+		`BAD SYMBOL NAME "::=" AT symbols[0].productions[0].elements[1].symbol`,
+	}
+	_, errs := convert(src, ReadJSON, func() {})
+	if len(errs) != len(want) {
+		t.Fatalf("got errors %v, want %v", errs, want)
+	}
+	for i := range want {
+		if errs[i] != want[i] {
+			t.Errorf("got error %q, want %q", errs[i], want[i])
+		}
+	}
+}
+
+// comments that readg couldn't read back as they stand are reported at
+// their paths, and left out
+func TestJSONBadComments(t *testing.T) {
+	src := `{"schema": "gtools-grammar/1", "head": "s",
+		"preamble": "> evil\nfoo ::= bar\n", "headnote": " # the start\n", "emptynote": "x", "trailer": "hello",
+		"symbols": [
+		{"name": "s", "kind": "nonterminal", "line": 1, "productions": [
+			{"line": 1, "comment": "# fine\n\n", "elements": [{"symbol": "x", "line": 1}]},
+			{"line": 2, "comment": "t ::= y\n", "elements": [{"symbol": "x", "line": 2}]}]},
+		{"name": "x", "kind": "terminal", "line": 1}]}`
+	want := []string{
+		`BAD COMMENT "t ::= y\n" AT symbols[0].productions[1].comment`,
+		`BAD COMMENT "> evil\nfoo ::= bar\n" AT preamble`,
+		`BAD COMMENT " # the start\n" AT headnote`,
+		`BAD COMMENT "x" AT emptynote`,
+		`BAD COMMENT "hello" AT trailer`,
+	}
+	got, errs := convert(src, ReadJSON, WriteGrammar)
+	if len(errs) != len(want) {
+		t.Fatalf("got errors %v, want %v", errs, want)
+	}
+	for i := range want {
+		if errs[i] != want[i] {
+			t.Errorf("got error %q, want %q", errs[i], want[i])
+		}
+	}
+	if wantg := "> s\n\n# fine\n\ns ::= x\n   |  x\n\n# terminals:   x\n"; got != wantg {
+		t.Errorf("got\n%s\nwant\n%s", got, wantg)
+	}
+}
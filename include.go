@@ -0,0 +1,143 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Grammars in several files.
+// The directive
+//    |
+//    |@ include expressions.gr
+//    |
+// makes readg read the named file as if its lines stood in place of the
+// directive, so a grammar can be split up by area.  A relative name is
+// taken from the directory of the file holding the directive, or from the
+// current directory if that is standard input.  Rules for a symbol in
+// several files are appended as if they were in one; the distinguished
+// and empty symbols may be declared again in another file, so that each
+// file can be read on its own, but declaring a different one is an error.
+// The directive is not kept, writeg puts out the grammar as one file.
+
+// what readg was reading when it went off to read an included file
+type includer struct {
+	in    io.Reader
+	ahead int    // runeahead
+	file  string // srcfile
+	line  int
+	col   int
+	ch    rune
+}
+
+// global variables private to this package
+var (
+	includes []includer // the files that are including others, outermost first
+)
+
+// static void getdirective()
+// get a directive line, called when ch == '@'
+func getdirective() {
+	var at span
+	var word []byte
+	var name string
+
+	at = here()
+	ch = nextch() // skip @
+	skipwhite()
+	for ch != ' ' && ch != '\t' && ch != '\n' && ch != REOF {
+		word = append(word, string(ch)...)
+		ch = nextch()
+	}
+	skipwhite()
+	name = strings.TrimSpace(restofline())
+	skipline()
+
+	if string(word) != "include" {
+		errorat("UNKNOWN DIRECTIVE @"+string(word), at)
+		return
+	}
+	if len(name) >= 2 && (name[0] == '"' || name[0] == '\'') && name[len(name)-1] == name[0] {
+		name = name[1 : len(name)-1]
+	}
+	if name == "" {
+		errorat("NO FILE TO INCLUDE", at)
+		return
+	}
+	include(name, at)
+}
+
+// include starts reading the named file, which is included at at;
+// ch must be the first character after the directive
+func include(name string, at span) {
+	var fp *os.File
+	var err error
+
+	if !filepath.IsAbs(name) && srcfile != "" {
+		name = filepath.Join(filepath.Dir(srcfile), name)
+	}
+	name = filepath.Clean(name)
+	if samefile(name, srcfile) {
+		errorat("FILE INCLUDES ITSELF "+name, at)
+		return
+	}
+	for _, inc := range includes {
+		if samefile(name, inc.file) {
+			errorat("FILE INCLUDES ITSELF "+name, at)
+			return
+		}
+	}
+	fp, err = os.Open(name)
+	if err != nil {
+		errorat("CANNOT INCLUDE "+name, at)
+		return
+	}
+
+	includes = append(includes, includer{stdin, runeahead, srcfile, line, col, ch})
+	stdin = fp
+	runeahead = -1
+	srcfile = name
+	line = 1
+	col = 0
+	ch = nextch()
+}
+
+// samefile reports whether the file names a and b name the same file,
+// comparing them as absolute paths so ./a.gr and a.gr are the same
+func samefile(a, b string) bool {
+	if a == "" || b == "" {
+		return a == b
+	}
+	absa, erra := filepath.Abs(a)
+	absb, errb := filepath.Abs(b)
+	if erra != nil || errb != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return absa == absb
+}
+
+// endinclude goes back to the file that included the one just read,
+// reporting whether there was one
+func endinclude() bool {
+	var inc includer
+
+	if len(includes) == 0 {
+		return false
+	}
+	if fp, ok := stdin.(io.Closer); ok {
+		_ = fp.Close()
+	}
+	inc = includes[len(includes)-1]
+	includes = includes[:len(includes)-1]
+	stdin = inc.in
+	runeahead = inc.ahead
+	srcfile = inc.file
+	line = inc.line
+	col = inc.col
+	ch = inc.ch
+	return true
+}
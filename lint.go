@@ -0,0 +1,375 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Lint checks.
+// Lint looks over the grammar just read for things that are legal but
+// probably not what was meant, each check having a name, a severity and a
+// line in the table below.  A check is turned off for the whole grammar
+// by a comment before the distinguished symbol is given, or for one rule
+// by a comment just before the rule,
+//    |
+//    |# glint disable bracketed-terminal unreachable
+//    |
+// and a problem with a symbol rather than a rule belongs to the first
+// rule of the symbol, or for a terminal, the rule it is first used in.
+
+type lintcheck struct {
+	id       string
+	severity string // error, warning or note
+	help     string
+	run      func()
+}
+
+var lintchecks = []lintcheck{
+	{"bracketed-terminal", "warning", "a <symbol> with no rules, so it is taken as a terminal", lintbracketed},
+	{"quoting", "warning", "terminals that differ only in quoting, as '+' \"+\" and +", lintquoting},
+	{"duplicate-alternative", "warning", "an alternative given twice, which gsqueeze drops", lintduplicates},
+	{"unreachable", "warning", "a symbol that can't be reached from the distinguished symbol", lintunreachable},
+	{"nonproductive", "error", "a nonterminal that can't produce any string of terminals", lintnonproductive},
+	{"empty-in-sequence", "warning", "the empty symbol used alongside other symbols in a rule", lintempty},
+	{"wirth-period", "warning", "rules ending in a . terminal, Wirth's terminator read without -period", lintperiod},
+}
+
+// global variables private to this package
+var _lint struct {
+	check    *lintcheck               // the check being run
+	disabled map[string]bool          // checks off for the whole grammar
+	rules    map[span]map[string]bool // checks off for the rule given at a span
+	trees    map[PSYMBOL]*gnode       // the rules of each nonterminal as a tree
+	found    []Diagnostic             // what the checks found
+}
+
+func Lint(disable []string) ([]Diagnostic, error) {
+	return lint(disable)
+}
+
+// WriteLintChecks lists the checks of Lint on stdout
+func WriteLintChecks() {
+	for _, c := range lintchecks {
+		fprintf(stdout, "%-22s %-8s %s\n", c.id, c.severity, c.help)
+	}
+}
+
+// lintknown reports whether id names a check
+func lintknown(id string) bool {
+	for _, c := range lintchecks {
+		if c.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// lintdirectives adds the checks turned off by the glint comments in the
+// source lines text to off
+func lintdirectives(text string, off map[string]bool) {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), string(COMMENT)))
+		words := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == ','
+		})
+		if len(words) < 2 || strings.TrimSuffix(words[0], ":") != "glint" || words[1] != "disable" {
+			continue
+		}
+		for _, id := range words[2:] {
+			if !lintknown(id) {
+				_lint.found = append(_lint.found, Diagnostic{
+					Message:  "unknown check " + id + " in glint comment",
+					File:     srcfile,
+					Severity: "warning",
+				})
+			}
+			off[id] = true
+		}
+	}
+}
+
+// lintreport notes a problem found by the current check in rule p, or the
+// grammar as a whole if p is nil
+func lintreport(p PPRODUCTION, sp span, msg string) {
+	var d Diagnostic
+
+	if p != nil && _lint.rules[p.lhs][_lint.check.id] {
+		return
+	}
+	d = diagnostic(msg, sp)
+	d.Severity = _lint.check.severity
+	d.Check = _lint.check.id
+	_lint.found = append(_lint.found, d)
+}
+
+// lintsyms lists the symbols that are neither EBNF metasymbols nor the
+// empty symbol
+func lintsyms() []PSYMBOL {
+	var syms []PSYMBOL
+	var s PSYMBOL
+
+	for s = symlist; s != nil; s = s.next {
+		if _, _, meta := metakind(s); !meta && s != emptypt {
+			syms = append(syms, s)
+		}
+	}
+	return syms
+}
+
+// lintuse finds the rule s is first used in, and where, or the first
+// rule of s if it is a nonterminal given at the left before any use
+func lintuse(s PSYMBOL) (PPRODUCTION, span) {
+	var ss PSYMBOL
+	var p PPRODUCTION
+	var e PELEMENT
+
+	if s.data != nil && s.data.lhs == s.pos {
+		return s.data, s.pos
+	}
+	for ss = symlist; ss != nil; ss = ss.next {
+		for p = ss.data; p != nil; p = p.next {
+			for e = p.data; e != nil; e = e.next {
+				if e.data == s && e.pos == s.pos {
+					return p, e.pos
+				}
+			}
+		}
+	}
+	if s.data != nil {
+		return s.data, s.data.lhs
+	}
+	return nil, s.pos
+}
+
+// lint
+// run the checks not disabled on the global grammar structure
+func lint(disable []string) ([]Diagnostic, error) {
+	var s PSYMBOL
+	var p PPRODUCTION
+
+	_lint.found = nil
+	_lint.disabled = map[string]bool{}
+	for _, id := range disable {
+		if !lintknown(id) {
+			return nil, fmt.Errorf("unknown check %s", id)
+		}
+		_lint.disabled[id] = true
+	}
+	lintdirectives(preamble, _lint.disabled)
+	_lint.rules = map[span]map[string]bool{}
+	for s = symlist; s != nil; s = s.next {
+		for p = s.data; p != nil; p = p.next {
+			if p.comment != "" && p.lhs.line != 0 {
+				if _lint.rules[p.lhs] == nil {
+					_lint.rules[p.lhs] = map[string]bool{}
+				}
+				lintdirectives(p.comment, _lint.rules[p.lhs])
+			}
+		}
+	}
+
+	// building the trees reports bracketing errors, once
+	_lint.trees = map[PSYMBOL]*gnode{}
+	for s = symlist; s != nil; s = s.next {
+		if NONTERMINAL(s) {
+			_lint.trees[s] = rulestotree(s)
+		}
+	}
+
+	for i := range lintchecks {
+		if !_lint.disabled[lintchecks[i].id] {
+			_lint.check = &lintchecks[i]
+			lintchecks[i].run()
+		}
+	}
+
+	sort.SliceStable(_lint.found, func(i, j int) bool {
+		a, b := _lint.found[i], _lint.found[j]
+		if a.File != b.File {
+			return a.File < b.File
+		} else if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+	return _lint.found, nil
+}
+
+// a <symbol> with no rules
+func lintbracketed() {
+	for _, s := range lintsyms() {
+		if TERMINAL(s) && isbracketed(symname(s)) {
+			p, sp := lintuse(s)
+			lintreport(p, sp, symname(s)+" has no rules, so it is a terminal")
+		}
+	}
+}
+
+// terminals that differ only in quoting
+func lintquoting() {
+	var first = map[string]PSYMBOL{}
+
+	for _, s := range lintsyms() {
+		if NONTERMINAL(s) {
+			continue
+		}
+		name := symname(s)
+		text := name
+		if isquoted(name) {
+			text = name[1 : len(name)-1]
+		}
+		if f, ok := first[text]; !ok {
+			first[text] = s
+		} else {
+			p, sp := lintuse(s)
+			lintreport(p, sp, name+" differs from "+symname(f)+" only in quoting")
+		}
+	}
+}
+
+// the same alternative given twice
+func lintduplicates() {
+	var p, q PPRODUCTION
+
+	for _, s := range lintsyms() {
+		for p = s.data; p != nil; p = p.next {
+			if hasmeta(p.data) {
+				continue
+			}
+			for q = s.data; q != p; q = q.next {
+				if !hasmeta(q.data) && samerule(q, p) {
+					lintreport(p, p.pos, sprintf("alternative of %s given again, first at %d:%d", symname(s), q.pos.line, q.pos.col))
+					break
+				}
+			}
+		}
+	}
+}
+
+// symbols that can't be reached
+func lintunreachable() {
+	if head == nil {
+		return
+	}
+	reachsetup()
+	reachtouch(head)
+	for _, s := range lintsyms() {
+		if s.state == UNTOUCHED {
+			p, sp := lintuse(s)
+			lintreport(p, sp, symname(s)+" can't be reached from "+symname(head))
+		}
+	}
+}
+
+// lintproduces reports whether rule tree t can produce a string of
+// terminals, given the nonterminals known to
+func lintproduces(t *gnode, productive map[PSYMBOL]bool) bool {
+	switch t.kind {
+	case GSYMBOL:
+		return TERMINAL(t.sym) || productive[t.sym]
+	case GSEQ:
+		for _, k := range t.kids {
+			if !lintproduces(k, productive) {
+				return false
+			}
+		}
+		return true
+	case GOPT, GREP: // nothing at all will do
+		return true
+	}
+	for _, k := range t.kids {
+		if lintproduces(k, productive) {
+			return true
+		}
+	}
+	return false
+}
+
+// nonterminals that can't produce a string of terminals
+func lintnonproductive() {
+	var productive = map[PSYMBOL]bool{}
+	var changed = true
+
+	for changed {
+		changed = false
+		for s, t := range _lint.trees {
+			if !productive[s] && lintproduces(t, productive) {
+				productive[s] = true
+				changed = true
+			}
+		}
+	}
+	for _, s := range lintsyms() {
+		if NONTERMINAL(s) && !productive[s] {
+			lintreport(s.data, s.data.lhs, symname(s)+" can't produce any string of terminals")
+		}
+	}
+}
+
+// the empty symbol alongside other symbols
+func lintempty() {
+	var p PPRODUCTION
+	var e PELEMENT
+	var empty PELEMENT
+	var others bool
+
+	if emptypt == nil {
+		return
+	}
+	for _, s := range lintsyms() {
+		for p = s.data; p != nil; p = p.next {
+			empty = nil
+			others = false
+			for e = p.data; e != nil; e = e.next {
+				if e.data == emptypt {
+					if empty == nil {
+						empty = e
+					}
+				} else if _, _, meta := metakind(e.data); !meta {
+					others = true
+				}
+			}
+			if empty != nil && others {
+				lintreport(p, empty.pos, symname(emptypt)+" is used alongside other symbols")
+			}
+		}
+	}
+}
+
+// rules ending with a . terminal and nothing else using it
+func lintperiod() {
+	var period PSYMBOL
+	var p PPRODUCTION
+	var e PELEMENT
+	var first PELEMENT
+	var firstp PPRODUCTION
+
+	period = lookupname(".")
+	if period == nil || NONTERMINAL(period) {
+		return
+	}
+	for _, s := range lintsyms() {
+		for p = s.data; p != nil; p = p.next {
+			for e = p.data; e != nil; e = e.next {
+				if e.data != period {
+					continue
+				}
+				// the last element of the last production of the rule
+				if e.next != nil || (p.next != nil && p.next.lhs == p.lhs) {
+					return
+				}
+				if first == nil || e.pos.line < first.pos.line {
+					first = e
+					firstp = p
+				}
+			}
+		}
+	}
+	if first != nil {
+		lintreport(firstp, first.pos, "rules end with a . terminal, as in Wirth's notation; read them with -period")
+	}
+}
@@ -0,0 +1,90 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// one small grammar for each check, each giving just what it is for
+func TestLint(t *testing.T) {
+	defer SetDiagnostics(nil)
+
+	for _, tc := range []struct {
+		name    string
+		src     string
+		disable []string
+		want    []string
+	}{
+		{"bracketed-terminal",
+			"> <s>\n<s> ::= a <b>\n",
+			nil,
+			[]string{"2:11 bracketed-terminal: <b> has no rules, so it is a terminal"}},
+		{"quoting",
+			"> s\ns ::= '+' +\n",
+			nil,
+			[]string{"2:11 quoting: + differs from '+' only in quoting"}},
+		{"duplicate-alternative",
+			"> s\ns ::= a\n  | b\n  | a\n",
+			nil,
+			[]string{"4:5 duplicate-alternative: alternative of s given again, first at 2:7"}},
+		{"unreachable",
+			"> s\ns ::= a\nt ::= b\n",
+			nil,
+			[]string{
+				"3:1 unreachable: t can't be reached from s",
+				"3:7 unreachable: b can't be reached from s",
+			}},
+		{"nonproductive",
+			"> s\ns ::= a\n  | t\nt ::= b t\n",
+			nil,
+			[]string{"4:1 nonproductive: t can't produce any string of terminals"}},
+		{"empty-in-sequence",
+			"> s\n/ ''\ns ::= a '' b\n",
+			nil,
+			[]string{"3:9 empty-in-sequence: '' is used alongside other symbols"}},
+		{"wirth-period",
+			"> s\ns ::= t .\nt ::= a .\n",
+			nil,
+			[]string{"2:9 wirth-period: rules end with a . terminal, as in Wirth's notation; read them with -period"}},
+		{"disabled for a rule",
+			"> s\ns ::= a\n  | t\n\n# glint disable unreachable, nonproductive\nt ::= b t\nu ::= c\n",
+			nil,
+			[]string{
+				"7:1 unreachable: u can't be reached from s",
+				"7:7 unreachable: c can't be reached from s",
+			}},
+		{"disabled for the grammar",
+			"# glint disable unreachable\n> s\ns ::= a\nt ::= b\n",
+			nil,
+			nil},
+		{"disabled by flag",
+			"> s\ns ::= a\nt ::= b\n",
+			[]string{"unreachable"},
+			nil},
+		{"unknown check",
+			"> s\n# glint disable nosuch\ns ::= a\n",
+			nil,
+			[]string{"0:0 : unknown check nosuch in glint comment"}},
+	} {
+		SetDiagnostics(func(d Diagnostic) {
+			t.Errorf("%s: reading: %s", tc.name, d)
+		})
+		SetStdinReader(strings.NewReader(tc.src))
+		ReadGrammar()
+		found, err := Lint(tc.disable)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		var got []string
+		for _, d := range found {
+			got = append(got, fmt.Sprintf("%d:%d %s: %s", d.Line, d.Col, d.Check, d.Message))
+		}
+		if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+			t.Errorf("%s: got\n%s\nwant\n%s", tc.name, strings.Join(got, "\n"), strings.Join(tc.want, "\n"))
+		}
+	}
+}
@@ -0,0 +1,707 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Language server.
+// ServeLSP speaks the Language Server Protocol on in and out, so that an
+// editor can show the errors readg and gdeebnf find in a grammar as it is
+// typed, go from a symbol to its rules, find the uses of a symbol, rename
+// it, show its start and follow sets, complete the names of nonterminals
+// and give an outline of the rules.  Each request reads the grammar again
+// from the text the editor sent, so the server keeps nothing between
+// requests but the text of the open documents.  Since in and out are just
+// a reader and a writer, a client in the same process can talk to it
+// through a pair of pipes.
+//
+// Positions in the protocol count lines from 0 and characters in UTF-16
+// code units; spans count both from 1 and characters as characters, so
+// the text of the line is needed to go from one to the other.
+
+func ServeLSP(in io.Reader, out io.Writer) error {
+	return servelsp(in, out)
+}
+
+// a message of JSON-RPC 2.0, a request, response or notification
+type lspmessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// an error to send back in a response
+type lsperror struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// error codes of JSON-RPC and the protocol
+const (
+	lspparseerror     = -32700
+	lspinvalidparams  = -32602
+	lspnomethod       = -32601
+	lspinternalerror  = -32603
+	lsprequestfailed  = -32803
+	lspseverityerror  = 1
+	lspseveritywarn   = 2
+	lspseveritynote   = 3
+	lspsymbolfunction = 12 // SymbolKind for the outline of rules
+	lspitemfunction   = 3  // CompletionItemKind for nonterminals
+)
+
+type lsppos struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lsprange struct {
+	Start lsppos `json:"start"`
+	End   lsppos `json:"end"`
+}
+
+type lsplocation struct {
+	URI   string   `json:"uri"`
+	Range lsprange `json:"range"`
+}
+
+type lspdiagnostic struct {
+	Range    lsprange `json:"range"`
+	Severity int      `json:"severity"`
+	Code     string   `json:"code,omitempty"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lsptextedit struct {
+	Range   lsprange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspdocsymbol struct {
+	Name           string   `json:"name"`
+	Detail         string   `json:"detail,omitempty"`
+	Kind           int      `json:"kind"`
+	Range          lsprange `json:"range"`
+	SelectionRange lsprange `json:"selectionRange"`
+}
+
+type lspcompletion struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// the parameters of the requests, as much of them as is used
+type lspparams struct {
+	TextDocument struct {
+		URI  string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+	Position lsppos `json:"position"`
+	Context  struct {
+		IncludeDeclaration bool `json:"includeDeclaration"`
+	} `json:"context"`
+	NewName string `json:"newName"`
+}
+
+// where a symbol is named in the text
+type lspoccur struct {
+	s   PSYMBOL
+	pos span
+	def bool // at the left of a rule
+}
+
+// global variables private to this package
+var _lsp struct {
+	in        *bufio.Reader
+	out       io.Writer
+	docs      map[string]string   // the text of the open documents by URI
+	published map[string][]string // the URIs diagnostics went to for each document
+	lines     map[string][]string // the lines of files, for the current message
+	shutdown  bool                // shutdown has been asked for
+}
+
+// servelsp reads and answers messages until the client says exit
+func servelsp(in io.Reader, out io.Writer) error {
+	var msg lspmessage
+	var body []byte
+	var err error
+
+	_lsp.in = bufio.NewReader(in)
+	_lsp.out = out
+	_lsp.docs = map[string]string{}
+	_lsp.published = map[string][]string{}
+	_lsp.shutdown = false
+
+	// the grammars are read from strings, and errors go to the client
+	stdin = nil
+	defer SetDiagnostics(diagnose)
+	SetDiagnostics(func(Diagnostic) {})
+
+	for {
+		if body, err = lspread(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		msg = lspmessage{}
+		if err = json.Unmarshal(body, &msg); err != nil {
+			lspreply(nil, nil, &lsperror{lspparseerror, err.Error()})
+			continue
+		}
+		if msg.Method == "exit" {
+			if !_lsp.shutdown {
+				return errors.New("exit without shutdown")
+			}
+			return nil
+		}
+		_lsp.lines = map[string][]string{}
+		result, lerr := lspdispatch(&msg)
+		if msg.ID != nil && msg.Method != "" { // a request, not a notification
+			lspreply(msg.ID, result, lerr)
+		}
+	}
+}
+
+// lspread reads the body of the next message
+func lspread() ([]byte, error) {
+	var length = -1
+
+	for {
+		header, err := _lsp.in.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && header == "" {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+		header = strings.TrimRight(header, "\r\n")
+		if header == "" {
+			break
+		}
+		if name, value, ok := strings.Cut(header, ":"); ok && strings.EqualFold(name, "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("bad Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("message without Content-Length")
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(_lsp.in, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// lspwrite sends one message
+func lspwrite(msg any) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		panic(err)
+	}
+	_, _ = fmt.Fprintf(_lsp.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// lspreply sends the response to the request with the given id
+func lspreply(id json.RawMessage, result any, err *lsperror) {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	if err != nil {
+		lspwrite(map[string]any{"jsonrpc": "2.0", "id": id, "error": err})
+	} else {
+		lspwrite(map[string]any{"jsonrpc": "2.0", "id": id, "result": result})
+	}
+}
+
+// lspnotify sends a notification
+func lspnotify(method string, params any) {
+	lspwrite(map[string]any{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+// lspdispatch does what msg asks, a grammar that upsets a transformation
+// being an error in the request rather than the end of the server
+func lspdispatch(msg *lspmessage) (result any, lerr *lsperror) {
+	var p lspparams
+
+	defer func() {
+		if r := recover(); r != nil {
+			result = nil
+			lerr = &lsperror{lspinternalerror, fmt.Sprint(r)}
+		}
+	}()
+
+	if len(msg.Params) != 0 {
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, &lsperror{lspinvalidparams, err.Error()}
+		}
+	}
+	uri := p.TextDocument.URI
+
+	switch msg.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":       1, // the whole text on each change
+				"definitionProvider":     true,
+				"referencesProvider":     true,
+				"renameProvider":         true,
+				"hoverProvider":          true,
+				"completionProvider":     map[string]any{},
+				"documentSymbolProvider": true,
+			},
+			"serverInfo": map[string]any{"name": "gtools"},
+		}, nil
+	case "shutdown":
+		_lsp.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		_lsp.docs[uri] = p.TextDocument.Text
+		lspdiagnose(uri)
+	case "textDocument/didChange":
+		if n := len(p.ContentChanges); n > 0 {
+			_lsp.docs[uri] = p.ContentChanges[n-1].Text
+		}
+		lspdiagnose(uri)
+	case "textDocument/didSave":
+		lspdiagnose(uri) // an included file may have changed
+	case "textDocument/didClose":
+		delete(_lsp.docs, uri)
+		for _, to := range _lsp.published[uri] {
+			lspnotify("textDocument/publishDiagnostics", map[string]any{"uri": to, "diagnostics": []lspdiagnostic{}})
+		}
+		delete(_lsp.published, uri)
+	case "textDocument/definition":
+		return lspdefinition(uri, p.Position), nil
+	case "textDocument/references":
+		return lspreferences(uri, p.Position, p.Context.IncludeDeclaration), nil
+	case "textDocument/rename":
+		return lsprename(uri, p.Position, p.NewName)
+	case "textDocument/hover":
+		return lsphover(uri, p.Position), nil
+	case "textDocument/completion":
+		return lspcompletions(uri), nil
+	case "textDocument/documentSymbol":
+		return lspoutline(uri), nil
+	default:
+		if msg.ID != nil && !strings.HasPrefix(msg.Method, "$/") {
+			return nil, &lsperror{lspnomethod, "method not found: " + msg.Method}
+		}
+	}
+	return nil, nil
+}
+
+// the path of the file named by uri, "" if it is not a file
+func lsppath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return filepath.Clean(filepath.FromSlash(u.Path))
+}
+
+// the URI of the file a span is in, the document read if it has no name
+func lspuri(file string, doc string) string {
+	if file == "" || file == lsppath(doc) {
+		return doc
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(file)}
+	return u.String()
+}
+
+// the lines of the text at uri, open in the editor or on disk
+func lsplines(uri string) []string {
+	if text, ok := _lsp.lines[uri]; ok {
+		return text
+	}
+	text, ok := _lsp.docs[uri]
+	if !ok {
+		if data, err := os.ReadFile(lsppath(uri)); err == nil {
+			text = string(data)
+		}
+	}
+	_lsp.lines[uri] = strings.Split(text, "\n")
+	return _lsp.lines[uri]
+}
+
+// the UTF-16 offset of the character col, counting from 1, of a line
+func lspchar(text string, col int) int {
+	var n int
+
+	for _, r := range text {
+		if col <= 1 {
+			break
+		}
+		n = n + len(utf16.Encode([]rune{r}))
+		col--
+	}
+	return n
+}
+
+// the character, counting from 1, at a UTF-16 offset of a line
+func lspcol(text string, char int) int {
+	var col = 1
+
+	for _, r := range text {
+		if char <= 0 {
+			break
+		}
+		char = char - len(utf16.Encode([]rune{r}))
+		col++
+	}
+	return col
+}
+
+// lsprangeof is sp as a range in the lines of the text at uri, the whole
+// line if sp gives no column
+func lsprangeof(sp span, uri string) lsprange {
+	var rng lsprange
+	var lines []string
+
+	if sp.line == 0 {
+		return rng
+	}
+	lines = lsplines(uri)
+	text := func(n int) string {
+		if n >= 1 && n <= len(lines) {
+			return lines[n-1]
+		}
+		return ""
+	}
+	rng.Start.Line = sp.line - 1
+	rng.End.Line = sp.endline - 1
+	if sp.col == 0 {
+		rng.End.Line = sp.line - 1
+		rng.End.Character = len(utf16.Encode([]rune(text(sp.line))))
+		return rng
+	}
+	rng.Start.Character = lspchar(text(sp.line), sp.col)
+	rng.End.Character = lspchar(text(sp.endline), sp.endcol)
+	return rng
+}
+
+// lsplocationof is the location of sp, read from the document doc
+func lsplocationof(sp span, doc string) lsplocation {
+	uri := lspuri(sp.file, doc)
+	return lsplocation{URI: uri, Range: lsprangeof(sp, uri)}
+}
+
+// lspload reads the document at uri into the global grammar structure
+func lspload(uri string) {
+	SetStdinReader(strings.NewReader(_lsp.docs[uri]))
+	SetSourceName(lsppath(uri))
+	readg()
+}
+
+// lspdiagnose publishes the errors readg and gdeebnf find in the document
+// at uri, those in files it includes going to those files
+func lspdiagnose(uri string) {
+	var found []Diagnostic
+	var byuri map[string][]lspdiagnostic
+	var seen map[Diagnostic]bool
+	var to []string
+
+	SetDiagnostics(func(d Diagnostic) {
+		found = append(found, d)
+	})
+	defer SetDiagnostics(func(Diagnostic) {})
+	func() {
+		defer func() {
+			if r := recover(); r != nil { // say what went wrong
+				found = append(found, Diagnostic{Message: fmt.Sprint(r)})
+			}
+		}()
+		lspload(uri)
+		gdeebnf()
+	}()
+
+	byuri = map[string][]lspdiagnostic{uri: {}}
+	seen = map[Diagnostic]bool{}
+	for _, d := range found {
+		if seen[d] {
+			continue
+		}
+		seen[d] = true
+		sp := span{file: d.File, line: d.Line, col: d.Col, endline: d.EndLine, endcol: d.EndCol}
+		at := lspuri(sp.file, uri)
+		severity := lspseverityerror
+		switch d.Severity {
+		case "warning":
+			severity = lspseveritywarn
+		case "note":
+			severity = lspseveritynote
+		}
+		byuri[at] = append(byuri[at], lspdiagnostic{
+			Range:    lsprangeof(sp, at),
+			Severity: severity,
+			Code:     diagrule(d),
+			Source:   "gtools",
+			Message:  d.Message,
+		})
+	}
+
+	// clear what was published before and isn't now
+	for _, old := range _lsp.published[uri] {
+		if _, ok := byuri[old]; !ok {
+			byuri[old] = []lspdiagnostic{}
+		}
+	}
+	for at, diags := range byuri {
+		lspnotify("textDocument/publishDiagnostics", map[string]any{"uri": at, "diagnostics": diags})
+		if len(diags) != 0 {
+			to = append(to, at)
+		}
+	}
+	_lsp.published[uri] = to
+}
+
+// lspoccurrences lists where the symbols of the grammar just read are
+// named, leaving out the EBNF metasymbols and anything made up
+func lspoccurrences() []lspoccur {
+	var occurs []lspoccur
+	var s PSYMBOL
+	var p PPRODUCTION
+	var e PELEMENT
+	var lhs span
+
+	named := func(sp span) bool {
+		return sp.line != 0 && (sp.line != sp.endline || sp.col != sp.endcol)
+	}
+	if head != nil && named(headat) {
+		occurs = append(occurs, lspoccur{head, headat, false})
+	}
+	if emptypt != nil && named(emptyat) {
+		occurs = append(occurs, lspoccur{emptypt, emptyat, false})
+	}
+	for s = symlist; s != nil; s = s.next {
+		lhs = span{}
+		for p = s.data; p != nil; p = p.next {
+			if named(p.lhs) && p.lhs != lhs { // the bars of a rule share it
+				lhs = p.lhs
+				occurs = append(occurs, lspoccur{s, lhs, true})
+			}
+			for e = p.data; e != nil; e = e.next {
+				if _, _, meta := metakind(e.data); !meta && named(e.pos) {
+					occurs = append(occurs, lspoccur{e.data, e.pos, false})
+				}
+			}
+		}
+	}
+	return occurs
+}
+
+// lspsymbolat reads the document at uri and finds the symbol named at
+// pos, nil if there is none
+func lspsymbolat(uri string, pos lsppos) PSYMBOL {
+	var line, col int
+	var lines []string
+	var file string
+	var near PSYMBOL
+
+	lspload(uri)
+	lines = lsplines(uri)
+	line = pos.Line + 1
+	if line < 1 || line > len(lines) {
+		return nil
+	}
+	col = lspcol(lines[line-1], pos.Character)
+	file = lsppath(uri)
+	for _, o := range lspoccurrences() {
+		if o.pos.file != file || o.pos.line != line || o.pos.endline != line {
+			continue
+		}
+		if o.pos.col <= col && col < o.pos.endcol {
+			return o.s
+		} else if col == o.pos.endcol { // just after it
+			near = o.s
+		}
+	}
+	return near
+}
+
+// lspdefinition finds the rules of the symbol at pos
+func lspdefinition(uri string, pos lsppos) []lsplocation {
+	var locs = []lsplocation{}
+
+	s := lspsymbolat(uri, pos)
+	if s == nil {
+		return locs
+	}
+	for _, o := range lspoccurrences() {
+		if o.s == s && o.def {
+			locs = append(locs, lsplocationof(o.pos, uri))
+		}
+	}
+	return locs
+}
+
+// lspreferences finds the uses of the symbol at pos
+func lspreferences(uri string, pos lsppos, decl bool) []lsplocation {
+	var locs = []lsplocation{}
+
+	s := lspsymbolat(uri, pos)
+	if s == nil {
+		return locs
+	}
+	for _, o := range lspoccurrences() {
+		if o.s == s && (decl || !o.def) {
+			locs = append(locs, lsplocationof(o.pos, uri))
+		}
+	}
+	return locs
+}
+
+// lsprename renames the symbol at pos wherever it is named
+func lsprename(uri string, pos lsppos, name string) (any, *lsperror) {
+	var changes = map[string][]lsptextedit{}
+
+	s := lspsymbolat(uri, pos)
+	if s == nil {
+		return nil, &lsperror{lsprequestfailed, "no symbol to rename here"}
+	}
+	if name != symname(s) {
+		if err := validname(name); err != nil {
+			return nil, &lsperror{lsprequestfailed, err.Error()}
+		}
+	}
+	for _, o := range lspoccurrences() {
+		if o.s == s {
+			loc := lsplocationof(o.pos, uri)
+			changes[loc.URI] = append(changes[loc.URI], lsptextedit{loc.Range, name})
+		}
+	}
+	return map[string]any{"changes": changes}, nil
+}
+
+// lsphover describes the symbol at pos, with its start and follow sets
+// once the grammar is BNF
+func lsphover(uri string, pos lsppos) any {
+	var text []string
+	var what string
+	var rules int
+
+	s := lspsymbolat(uri, pos)
+	if s == nil {
+		return nil
+	}
+	name := symname(s)
+
+	switch {
+	case s == emptypt:
+		what = "the empty symbol"
+	case TERMINAL(s):
+		what = "terminal"
+	default:
+		rules = len(rulestotree(s).kids) // the alternatives, not the pieces of them
+		what = sprintf("nonterminal, %d rules", rules)
+		if rules == 1 {
+			what = "nonterminal, 1 rule"
+		}
+	}
+	if s == head {
+		what = what + ", the distinguished symbol"
+	} else if head != nil {
+		reachsetup()
+		reachtouch(head)
+		if s.state == TOUCHED {
+			what = what + ", reachable from " + symname(head)
+		} else {
+			what = what + ", not reachable from " + symname(head)
+		}
+	}
+	text = append(text, "`"+name+"` "+what)
+	if desc := strings.TrimSpace(s.comment); desc != "" {
+		text = append(text, desc)
+	}
+
+	// gstartfollow treats the empty symbol as a terminal, so get rid of it
+	if NONTERMINAL(s) {
+		gdeebnf()
+		if emptypt != nil && head != nil {
+			deempty()
+		}
+		startfollow()
+		text = append(text, "start: `"+strings.Join(jsonnames(s.starter), " ")+"`")
+		text = append(text, "follow: `"+strings.Join(jsonnames(s.follows), " ")+"`")
+	}
+	return map[string]any{
+		"contents": map[string]any{"kind": "markdown", "value": strings.Join(text, "\n\n")},
+	}
+}
+
+// lspcompletions offers the nonterminals of the document at uri
+func lspcompletions(uri string) []lspcompletion {
+	var items = []lspcompletion{}
+	var s PSYMBOL
+
+	lspload(uri)
+	for s = symlist; s != nil; s = s.next {
+		if NONTERMINAL(s) {
+			items = append(items, lspcompletion{Label: symname(s), Kind: lspitemfunction, Detail: lspdetail(s)})
+		}
+	}
+	return items
+}
+
+// lspdetail is the first line of the description of s
+func lspdetail(s PSYMBOL) string {
+	desc, _, _ := strings.Cut(strings.TrimSpace(s.comment), "\n")
+	return desc
+}
+
+// lspoutline lists the rules of the document at uri, one for each place
+// a symbol is given at the left of ::=
+func lspoutline(uri string) []lspdocsymbol {
+	var outline = []lspdocsymbol{}
+	var s PSYMBOL
+	var p PPRODUCTION
+	var file string
+
+	lspload(uri)
+	file = lsppath(uri)
+	for s = symlist; s != nil; s = s.next {
+		for p = s.data; p != nil; p = p.next {
+			if p.lhs.line == 0 || p.lhs.file != file {
+				continue
+			}
+			// the rule goes on to the last production given with it
+			lhs := p.lhs
+			for p.next != nil && p.next.lhs == lhs {
+				p = p.next
+			}
+			outline = append(outline, lspdocsymbol{
+				Name:           symname(s),
+				Detail:         lspdetail(s),
+				Kind:           lspsymbolfunction,
+				Range:          lsprangeof(spanto(lhs, p.pos), uri),
+				SelectionRange: lsprangeof(lhs, uri),
+			})
+		}
+	}
+	sort.SliceStable(outline, func(i, j int) bool {
+		a, b := outline[i].Range.Start, outline[j].Range.Start
+		return a.Line < b.Line || (a.Line == b.Line && a.Character < b.Character)
+	})
+	return outline
+}
@@ -0,0 +1,258 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// the grammar the client opens, with an error on its last line
+const lspfixture = `> expr
+
+# an expression
+expr ::= term '+' expr
+      |  term
+term ::= 'x'
+oops
+`
+
+const lspfixtureuri = "file:///grammars/fixture.gr"
+
+// a client in the same process, talking to ServeLSP through pipes
+type lspclient struct {
+	t      *testing.T
+	in     *bufio.Reader // what the server sends
+	out    io.Writer     // what the server reads
+	nextid int
+	notes  []lspmessage // notifications read while waiting for a response
+}
+
+// send writes one message with its Content-Length header
+func (c *lspclient) send(msg map[string]any) {
+	msg["jsonrpc"] = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err = fmt.Fprintf(c.out, "Content-Length: %d\r\n\r\n%s", len(body), body); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// receive reads one message from the server
+func (c *lspclient) receive() map[string]json.RawMessage {
+	var length int
+	var msg map[string]json.RawMessage
+
+	for {
+		header, err := c.in.ReadString('\n')
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		header = strings.TrimRight(header, "\r\n")
+		if header == "" {
+			break
+		}
+		if name, value, ok := strings.Cut(header, ":"); ok && name == "Content-Length" {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.in, body); err != nil {
+		c.t.Fatal(err)
+	}
+	if err := json.Unmarshal(body, &msg); err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+// notify sends a notification
+func (c *lspclient) notify(method string, params any) {
+	c.send(map[string]any{"method": method, "params": params})
+}
+
+// call sends a request and decodes the result of its response into result
+func (c *lspclient) call(method string, params any, result any) {
+	msg := c.request(method, params)
+	if e, ok := msg["error"]; ok {
+		c.t.Fatalf("%s: %s", method, e)
+	}
+	if err := json.Unmarshal(msg["result"], result); err != nil {
+		c.t.Fatalf("%s: %v", method, err)
+	}
+}
+
+// fail sends a request that should fail and returns the message of the
+// error in its response
+func (c *lspclient) fail(method string, params any) string {
+	var e struct {
+		Message string `json:"message"`
+	}
+
+	msg := c.request(method, params)
+	if _, ok := msg["error"]; !ok {
+		c.t.Fatalf("%s: want an error, got %s", method, msg["result"])
+	}
+	if err := json.Unmarshal(msg["error"], &e); err != nil {
+		c.t.Fatalf("%s: %v", method, err)
+	}
+	return e.Message
+}
+
+// request sends a request and returns its response, keeping the
+// notifications read while waiting for it
+func (c *lspclient) request(method string, params any) map[string]json.RawMessage {
+	c.nextid++
+	c.send(map[string]any{"id": c.nextid, "method": method, "params": params})
+	for {
+		msg := c.receive()
+		if _, ok := msg["id"]; ok {
+			return msg
+		}
+		var note lspmessage
+		_ = json.Unmarshal(msg["method"], &note.Method)
+		note.Params = msg["params"]
+		c.notes = append(c.notes, note)
+	}
+}
+
+// the position of the first character of the n'th use of name in the
+// fixture, counting from 0
+func lspfixturepos(name string, n int) map[string]any {
+	for i, text := range strings.Split(lspfixture, "\n") {
+		for col := 0; col+len(name) <= len(text); col++ {
+			if text[col:col+len(name)] == name {
+				if n == 0 {
+					return map[string]any{"line": i, "character": col}
+				}
+				n--
+			}
+		}
+	}
+	panic("no " + name + " in the fixture")
+}
+
+func TestServeLSP(t *testing.T) {
+	var done = make(chan error)
+
+	serverin, clientout := io.Pipe()
+	clientin, serverout := io.Pipe()
+	c := &lspclient{t: t, in: bufio.NewReader(clientin), out: clientout}
+	go func() {
+		done <- ServeLSP(serverin, serverout)
+		_ = serverout.Close()
+	}()
+	doc := map[string]any{"uri": lspfixtureuri}
+	at := func(name string, n int) map[string]any {
+		return map[string]any{"textDocument": doc, "position": lspfixturepos(name, n)}
+	}
+
+	var init struct {
+		Capabilities map[string]any `json:"capabilities"`
+	}
+	c.call("initialize", map[string]any{}, &init)
+	for _, capability := range []string{"definitionProvider", "referencesProvider", "hoverProvider", "renameProvider", "documentSymbolProvider"} {
+		if init.Capabilities[capability] != true {
+			t.Errorf("initialize: %s is not offered", capability)
+		}
+	}
+	c.notify("initialized", map[string]any{})
+
+	// the errors are published once the document is open
+	c.notify("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": lspfixtureuri, "languageId": "grammar", "version": 1, "text": lspfixture},
+	})
+	var published struct {
+		URI         string          `json:"uri"`
+		Diagnostics []lspdiagnostic `json:"diagnostics"`
+	}
+	msg := c.receive()
+	if err := json.Unmarshal(msg["params"], &published); err != nil {
+		t.Fatal(err)
+	}
+	if published.URI != lspfixtureuri || len(published.Diagnostics) != 1 {
+		t.Fatalf("didOpen: want one diagnostic for %s, got %+v", lspfixtureuri, published)
+	} else if d := published.Diagnostics[0]; d.Message != "MISSING ::= OR EQUIVALENT" || d.Range.Start.Line != 6 || d.Code != "MISSING_RULE_SYMBOL" {
+		t.Errorf("didOpen: want MISSING ::= OR EQUIVALENT on line 6, got %+v", d)
+	}
+
+	// term is used on lines 3 and 4, and its rule is on line 5
+	var locs []lsplocation
+	c.call("textDocument/definition", at("term", 0), &locs)
+	if len(locs) != 1 || locs[0].URI != lspfixtureuri || locs[0].Range != (lsprange{lsppos{5, 0}, lsppos{5, 4}}) {
+		t.Errorf("definition: want term on line 5, got %+v", locs)
+	}
+
+	locs = nil
+	c.call("textDocument/references", map[string]any{
+		"textDocument": doc, "position": lspfixturepos("term", 2), "context": map[string]any{"includeDeclaration": false},
+	}, &locs)
+	if len(locs) != 2 || locs[0].Range.Start != (lsppos{3, 9}) || locs[1].Range.Start != (lsppos{4, 9}) {
+		t.Errorf("references: want term on lines 3 and 4, got %+v", locs)
+	}
+
+	var hover struct {
+		Contents struct {
+			Kind  string `json:"kind"`
+			Value string `json:"value"`
+		} `json:"contents"`
+	}
+	c.call("textDocument/hover", at("expr", 2), &hover)
+	for _, want := range []string{"`expr` nonterminal, 2 rules, the distinguished symbol", "an expression", "start: `'x'`"} {
+		if !strings.Contains(hover.Contents.Value, want) {
+			t.Errorf("hover: want %q in %q", want, hover.Contents.Value)
+		}
+	}
+
+	var rename struct {
+		Changes map[string][]lsptextedit `json:"changes"`
+	}
+	c.call("textDocument/rename", map[string]any{
+		"textDocument": doc, "position": lspfixturepos("term", 0), "newName": "factor",
+	}, &rename)
+	if edits := rename.Changes[lspfixtureuri]; len(rename.Changes) != 1 || len(edits) != 3 {
+		t.Errorf("rename: want 3 edits to %s, got %+v", lspfixtureuri, rename.Changes)
+	} else {
+		for _, e := range edits {
+			if e.NewText != "factor" || e.Range.End.Character-e.Range.Start.Character != len("term") {
+				t.Errorf("rename: want term made factor, got %+v", e)
+			}
+		}
+	}
+
+	for _, name := range []string{"(", "}", "a b", "expr"} {
+		if msg := c.fail("textDocument/rename", map[string]any{
+			"textDocument": doc, "position": lspfixturepos("term", 0), "newName": name,
+		}); msg == "" {
+			t.Errorf("rename to %s: want a reason it can't be done", name)
+		}
+	}
+
+	var outline []lspdocsymbol
+	c.call("textDocument/documentSymbol", map[string]any{"textDocument": doc}, &outline)
+	if len(outline) != 2 || outline[0].Name != "expr" || outline[1].Name != "term" {
+		t.Errorf("documentSymbol: want expr and term, got %+v", outline)
+	} else if outline[0].Range.Start.Line != 3 || outline[0].Range.End.Line != 4 || outline[0].Detail != "an expression" {
+		t.Errorf("documentSymbol: want expr on lines 3 to 4, got %+v", outline[0])
+	}
+
+	var none any
+	c.call("shutdown", nil, &none)
+	c.notify("exit", nil)
+	if err := <-done; err != nil {
+		t.Errorf("exit: %v", err)
+	}
+	if len(c.notes) != 0 {
+		t.Errorf("unexpected notifications %+v", c.notes)
+	}
+}
@@ -1,8 +0,0 @@
-// gtools - a collection of grammar manipulation tools
-// Copyright (c) 2023 Michael D Henderson. All rights reserved.
-
-// Package main implements the command line tool
-package gtools
-
-func main() {
-}
@@ -0,0 +1,177 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// Output strings the grammar does not derive, for testing that a parser
+// rejects them.  Each is made from a string sample would write, picking
+// rules by their weights, by one mutation of its terminals: deleting one,
+// duplicating one, swapping two, or replacing one with another terminal
+// of the grammar.  Since a mutation often gives another string of the
+// language, each is checked with the recogniser of earley.go, and only
+// those it rejects are written, each once, after a comment line saying
+// how it was made
+//    |
+//    |# swap 1 <number> 2 +
+//    |+ <number> <number>
+//    |
+// A sample that runs on for more terminals than mutatelimit is dropped,
+// as is the sample itself if the recogniser would not take it.
+
+// the most terminals a sample may have before it is given up on
+const mutatelimit = 1000
+
+// global variables private to this package
+var _mutate struct {
+	tokens    []PSYMBOL // the terminals of the sample being made
+	terminals []PSYMBOL // the terminals used in the rules
+}
+
+// SampleMutants writes count strings that the grammar does not derive,
+// made by mutating samples of strings it does
+func SampleMutants(count int) error {
+	return samplemutants(count)
+}
+
+// mutatesym adds the terminals of a random derivation of s to the sample,
+// and reports whether they were kept under mutatelimit
+func mutatesym(s PSYMBOL) bool {
+	var p PPRODUCTION
+	var e PELEMENT
+	var pcount int
+
+	if s == emptypt {
+		return true
+	} else if TERMINAL(s) {
+		_mutate.tokens = append(_mutate.tokens, s)
+		return len(_mutate.tokens) <= mutatelimit
+	}
+	if p = weightpick(s); p == nil {
+		pcount = 0
+		for p = s.data; p != nil; p = p.next {
+			pcount++
+		}
+		p = s.data
+		for pnum := rand.Intn(pcount); pnum > 0; pnum-- {
+			p = p.next
+		}
+	}
+	for e = p.data; e != nil; e = e.next {
+		if !mutatesym(e.data) {
+			return false
+		}
+	}
+	return true
+}
+
+// mutateterminals lists the terminals used in the rules, in the order of
+// the symbol list
+func mutateterminals() []PSYMBOL {
+	var used map[PSYMBOL]bool
+	var terminals []PSYMBOL
+	var s PSYMBOL
+	var p PPRODUCTION
+	var e PELEMENT
+
+	used = map[PSYMBOL]bool{}
+	for s = symlist; s != nil; s = s.next {
+		for p = s.data; p != nil; p = p.next {
+			for e = p.data; e != nil; e = e.next {
+				used[e.data] = true
+			}
+		}
+	}
+	for s = symlist; s != nil; s = s.next {
+		if TERMINAL(s) && s != emptypt && used[s] {
+			terminals = append(terminals, s)
+		}
+	}
+	return terminals
+}
+
+// mutateone applies a random mutation to tokens, giving the mutant and
+// what was done, or nil if the mutation picked can't be done to them
+func mutateone(tokens []PSYMBOL) ([]PSYMBOL, string) {
+	var mutant []PSYMBOL
+	var i, j int
+	var t PSYMBOL
+
+	if len(tokens) == 0 {
+		return nil, ""
+	}
+	i = rand.Intn(len(tokens))
+	mutant = append(mutant, tokens...)
+	switch rand.Intn(4) {
+	case 0:
+		mutant = append(mutant[:i], mutant[i+1:]...)
+		return mutant, sprintf("delete %d %s", i+1, symname(tokens[i]))
+	case 1:
+		mutant = append(mutant[:i+1], mutant[i:]...)
+		return mutant, sprintf("duplicate %d %s", i+1, symname(tokens[i]))
+	case 2:
+		if j = rand.Intn(len(tokens)); tokens[i] == tokens[j] {
+			return nil, ""
+		} else if j < i {
+			i, j = j, i
+		}
+		mutant[i], mutant[j] = mutant[j], mutant[i]
+		return mutant, sprintf("swap %d %s %d %s", i+1, symname(tokens[i]), j+1, symname(tokens[j]))
+	default:
+		if t = _mutate.terminals[rand.Intn(len(_mutate.terminals))]; t == tokens[i] {
+			return nil, ""
+		}
+		mutant[i] = t
+		return mutant, sprintf("replace %d %s with %s", i+1, symname(tokens[i]), symname(t))
+	}
+}
+
+// mutatestring is tokens as sample writes them
+func mutatestring(tokens []PSYMBOL) string {
+	var names []string
+
+	for _, t := range tokens {
+		names = append(names, symname(t))
+	}
+	return strings.Join(names, " ")
+}
+
+// samplemutants
+// write count strings not in the language, each with how it was made
+func samplemutants(count int) error {
+	var written map[string]bool
+	var mutant []PSYMBOL
+	var how, str string
+	var n int
+
+	if head == nil || TERMINAL(head) {
+		return fmt.Errorf("no distinguished symbol")
+	}
+	_mutate.terminals = mutateterminals()
+	written = map[string]bool{}
+	for tries := 0; n < count && tries < 100*count; tries++ {
+		_mutate.tokens = nil
+		if !mutatesym(head) || !recognise(_mutate.tokens) {
+			continue
+		}
+		if mutant, how = mutateone(_mutate.tokens); mutant == nil {
+			continue
+		}
+		if str = mutatestring(mutant); written[str] || recognise(mutant) {
+			continue
+		}
+		written[str] = true
+		fputs("# "+how+"\n", stdout)
+		fputs(str+"\n", stdout)
+		n = n + 1
+	}
+	if n < count {
+		return fmt.Errorf("only %d of %d mutants were found not to be in the language", n, count)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// a small grammar with an empty rule, recursion on both sides, and a
+// terminal that is only ever the second of two
+const mutategrammar = "> <e>\n/ <empty>\n" +
+	"<e> ::= <t>\n" +
+	"      | <e> + <t>\n" +
+	"<t> ::= x\n" +
+	"      | - <t>\n" +
+	"      | <t> ! <o>\n" +
+	"<o> ::= <empty>\n" +
+	"      | y\n"
+
+// tokens looks up the terminals named in str, as sample writes them
+func tokens(t *testing.T, str string) []PSYMBOL {
+	var toks []PSYMBOL
+
+	for _, name := range strings.Fields(str) {
+		s := symlist
+		for s != nil && (NONTERMINAL(s) || symname(s) != name) {
+			s = s.next
+		}
+		if s == nil {
+			t.Fatalf("%q: no terminal %s", str, name)
+		}
+		toks = append(toks, s)
+	}
+	return toks
+}
+
+func TestRecognise(t *testing.T) {
+	var out bytes.Buffer
+
+	SetStdout(&out)
+	defer SetStdout(os.Stdout)
+	SetStdinReader(strings.NewReader(mutategrammar))
+	ReadGrammar()
+
+	for _, tc := range []struct {
+		str  string
+		want bool
+	}{
+		{"x", true},
+		{"x + - - x ! y + x !", true},
+		{"x ! ! y", true},
+		{"", false},
+		{"x +", false},
+		{"+ x", false},
+		{"x x", false},
+		{"x ! y y", false},
+		{"y", false},
+		{"- + x", false},
+	} {
+		if got := recognise(tokens(t, tc.str)); got != tc.want {
+			t.Errorf("%q: got %v, want %v", tc.str, got, tc.want)
+		}
+	}
+
+	// every sample is in the language
+	for i := 0; i < 100; i++ {
+		out.Reset()
+		Sample()
+		if !recognise(tokens(t, out.String())) {
+			t.Errorf("sample %q was not recognised", strings.TrimSpace(out.String()))
+		}
+	}
+}
+
+func TestSampleMutants(t *testing.T) {
+	var out bytes.Buffer
+
+	SetStdout(&out)
+	defer SetStdout(os.Stdout)
+	SetStdinReader(strings.NewReader(mutategrammar))
+	ReadGrammar()
+
+	if err := SampleMutants(20); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 40 {
+		t.Fatalf("got %d lines, want 40:\n%s", len(lines), out.String())
+	}
+	seen := map[string]bool{}
+	for i := 0; i < len(lines); i += 2 {
+		how, str := lines[i], lines[i+1]
+		if !strings.HasPrefix(how, "# ") {
+			t.Errorf("%q: want a comment saying how the mutant was made", how)
+		}
+		if seen[str] {
+			t.Errorf("%q: written twice", str)
+		}
+		seen[str] = true
+		if recognise(tokens(t, str)) {
+			t.Errorf("%s: mutant %q is in the language", how, str)
+		}
+	}
+}
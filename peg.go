@@ -0,0 +1,486 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"strconv"
+	"strings"
+)
+
+// PEG grammars.
+// writepeg writes the global grammar structure as a parsing expression
+// grammar, either in the syntax of pigeon or in the generic syntax of
+// Ford's paper.  Alternatives become ordered choices, [ ] becomes ?, and
+// { } becomes *.  Where gdeebnf invented a symbol for a bracketed group,
+// the group is put back, as writegoebnf does.
+//
+// A PEG is not a CFG: a choice commits to the first alternative that
+// matches, and ? and * never give back what they consumed.  So wherever
+// the conversion may change the language, a warning is reported against
+// the source line of the production involved:
+//   - an alternative that is a prefix of a later one, or that can match
+//     the empty string, hides the later one;
+//   - a [ ] or { } followed by something that can start the same way
+//     takes what should have been left for what follows it;
+//   - left recursion, direct or through symbols that can be empty, makes
+//     a PEG parser loop forever.
+
+func WritePEG(style string) {
+	writepeg(style)
+}
+
+// global variables private to this package
+var _peg struct {
+	pigeon   bool                         // pigeon syntax, else generic
+	names    map[PSYMBOL]string           // PEG text for each symbol
+	external []string                     // rules that are not defined
+	trees    map[PSYMBOL]*gnode           // rule trees of nonterminals
+	nullable map[PSYMBOL]bool             // can the symbol derive empty
+	first    map[PSYMBOL]map[PSYMBOL]bool // terminals that start the symbol
+	left     map[PSYMBOL][]pegref         // symbols leftmost in a symbol
+	contcol  int                          // column number for continuation
+}
+
+// a nonterminal that can be leftmost in a rule, and where
+type pegref struct {
+	sym  PSYMBOL
+	line int
+	pos  span
+}
+
+// grammar analysis over rule trees
+
+// nullable reports whether tree t can match the empty string
+func pegnullable(t *gnode) bool {
+	switch t.kind {
+	case GSYMBOL:
+		return t.sym == emptypt || _peg.nullable[t.sym]
+	case GSEQ:
+		for _, k := range t.kids {
+			if !pegnullable(k) {
+				return false
+			}
+		}
+		return true
+	case GALT:
+		for _, k := range t.kids {
+			if pegnullable(k) {
+				return true
+			}
+		}
+		return false
+	}
+	return true // GOPT and GREP
+}
+
+// pegfirst adds the terminals that can start tree t to set
+func pegfirst(t *gnode, set map[PSYMBOL]bool) {
+	switch t.kind {
+	case GSYMBOL:
+		if TERMINAL(t.sym) {
+			if t.sym != emptypt {
+				set[t.sym] = true
+			}
+			return
+		}
+		for ss := range _peg.first[t.sym] {
+			set[ss] = true
+		}
+	case GSEQ:
+		for _, k := range t.kids {
+			pegfirst(k, set)
+			if !pegnullable(k) {
+				return
+			}
+		}
+	default:
+		for _, k := range t.kids {
+			pegfirst(k, set)
+		}
+	}
+}
+
+// pegleft records the nonterminals that can be leftmost in tree t
+func pegleft(t *gnode, left *[]pegref) {
+	switch t.kind {
+	case GSYMBOL:
+		if NONTERMINAL(t.sym) {
+			for _, r := range *left {
+				if r.sym == t.sym {
+					return
+				}
+			}
+			*left = append(*left, pegref{t.sym, t.line, t.pos})
+		}
+	case GSEQ:
+		for _, k := range t.kids {
+			pegleft(k, left)
+			if !pegnullable(k) {
+				return
+			}
+		}
+	default:
+		for _, k := range t.kids {
+			pegleft(k, left)
+		}
+	}
+}
+
+// compute nullable and first sets of the nonterminals, iterating until
+// nothing changes, as deempty does for emptiness
+func peganalyze(order []PSYMBOL) {
+	var changed bool
+
+	_peg.nullable = map[PSYMBOL]bool{}
+	_peg.first = map[PSYMBOL]map[PSYMBOL]bool{}
+	for _, s := range order {
+		_peg.first[s] = map[PSYMBOL]bool{}
+	}
+	for firstTime := true; firstTime || changed; firstTime = false {
+		changed = false
+		for _, s := range order {
+			if !_peg.nullable[s] && pegnullable(_peg.trees[s]) {
+				_peg.nullable[s] = true
+				changed = true
+			}
+			n := len(_peg.first[s])
+			pegfirst(_peg.trees[s], _peg.first[s])
+			if len(_peg.first[s]) != n {
+				changed = true
+			}
+		}
+	}
+
+	_peg.left = map[PSYMBOL][]pegref{}
+	for _, s := range order {
+		var left []pegref
+		pegleft(_peg.trees[s], &left)
+		_peg.left[s] = left
+	}
+}
+
+// sametree reports whether trees a and b are written the same way
+func sametree(a, b *gnode) bool {
+	if a.kind != b.kind || a.sym != b.sym || len(a.kids) != len(b.kids) {
+		return false
+	}
+	for i := range a.kids {
+		if !sametree(a.kids[i], b.kids[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isprefix reports whether sequence a is a prefix of sequence b
+func isprefix(a, b *gnode) bool {
+	if len(a.kids) > len(b.kids) {
+		return false
+	}
+	for i := range a.kids {
+		if !sametree(a.kids[i], b.kids[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// overlaps reports whether two sets of terminals have any in common
+func overlaps(a, b map[PSYMBOL]bool) bool {
+	for s := range a {
+		if b[s] {
+			return true
+		}
+	}
+	return false
+}
+
+// report the places in tree t of symbol s where ordered choice or greedy
+// repetition changes the language
+func pegcheck(s PSYMBOL, t *gnode) {
+	var alts []*gnode
+
+	switch t.kind {
+	case GSYMBOL:
+		return
+	case GSEQ:
+		for i, k := range t.kids {
+			if (k.kind == GOPT || k.kind == GREP) && i+1 < len(t.kids) {
+				inner := map[PSYMBOL]bool{}
+				pegfirst(k, inner)
+				after := map[PSYMBOL]bool{}
+				pegfirst(&gnode{kind: GSEQ, kids: t.kids[i+1:]}, after)
+				if overlaps(inner, after) {
+					errormsg("PEG: GREEDY "+metanames[k.kind][0]+" "+metanames[k.kind][1]+" IN "+symname(s)+" MAY TAKE WHAT FOLLOWS IT", k.line)
+				}
+			}
+		}
+	}
+
+	alts = t.kids
+	if t.kind != GSEQ {
+	next:
+		for j, b := range alts {
+			for _, a := range alts[:j] {
+				if pegnullable(a) {
+					errormsg("PEG: ALTERNATIVE OF "+symname(s)+" IS NEVER TRIED, AN EARLIER ONE MATCHES EMPTY", b.line)
+					continue next
+				}
+			}
+			for _, a := range alts[:j] {
+				if isprefix(a, b) {
+					errormsg("PEG: ALTERNATIVE OF "+symname(s)+" IS NEVER TRIED, AN EARLIER ONE IS ITS PREFIX", b.line)
+					continue next
+				}
+			}
+		}
+	}
+	for _, k := range t.kids {
+		pegcheck(s, k)
+	}
+}
+
+// report left recursion, which a PEG parser can't handle: each symbol
+// that is directly left recursive on its own rule, and each longer cycle
+// once, from the first of its symbols in order
+func pegleftrecursion(order []PSYMBOL) {
+	var index map[PSYMBOL]int
+
+	index = map[PSYMBOL]int{}
+	for i, s := range order {
+		index[s] = i
+	}
+	for _, s := range order {
+		for _, r := range _peg.left[s] {
+			if r.sym == s {
+				errorin("PEG: "+symname(s)+" -> "+symname(s)+" IS LEFT RECURSIVE", r.line, r.pos)
+				break
+			}
+		}
+	}
+
+	for _, s := range order {
+		// search for a path of leftmost symbols from s back to s, through
+		// symbols later in order, so each cycle is found from its first
+		seen := map[PSYMBOL]bool{}
+		var path []pegref
+		var found bool
+		var walk func(x PSYMBOL)
+		walk = func(x PSYMBOL) {
+			for _, r := range _peg.left[x] {
+				y := r.sym
+				if y == x {
+					continue // reported above
+				}
+				if y == s {
+					path = append(path, r)
+					found = true
+					return
+				}
+				if index[y] > index[s] && !seen[y] {
+					seen[y] = true
+					walk(y)
+					if found {
+						path = append(path, r)
+						return
+					}
+				}
+			}
+		}
+		walk(s)
+		if !found {
+			continue
+		}
+		// path holds the steps of the cycle, last step first
+		names := []string{symname(s)}
+		for i := len(path) - 1; i >= 0; i-- {
+			names = append(names, symname(path[i].sym))
+		}
+		errorin("PEG: "+strings.Join(names, " -> ")+" IS LEFT RECURSIVE", path[len(path)-1].line, path[len(path)-1].pos)
+	}
+}
+
+// PEG output
+
+// decide on the PEG text for every symbol
+func pegnames() {
+	var s PSYMBOL
+	var used map[string]bool
+	var name, id, body string
+
+	_peg.names = map[PSYMBOL]string{}
+	_peg.external = nil
+	used = map[string]bool{}
+
+	for s = symlist; s != nil; s = s.next {
+		if NONTERMINAL(s) {
+			id = identifier(symname(s))
+			if id == "" || !('a' <= id[0] && id[0] <= 'z' || 'A' <= id[0] && id[0] <= 'Z') {
+				id = "R" + id
+			}
+			_peg.names[s] = uniquename(strings.ToUpper(id[:1])+id[1:], used)
+		}
+	}
+	for s = symlist; s != nil; s = s.next {
+		if NONTERMINAL(s) {
+			continue
+		}
+		name = symname(s)
+		if s == emptypt {
+			_peg.names[s] = ""
+		} else if isquoted(name) {
+			body = name[1 : len(name)-1]
+			_peg.names[s] = pegquote(body)
+		} else if id = identifier(name); id != "" {
+			if !('a' <= id[0] && id[0] <= 'z' || 'A' <= id[0] && id[0] <= 'Z') {
+				id = "T" + id
+			}
+			id = uniquename(strings.ToUpper(id[:1])+id[1:], used)
+			_peg.names[s] = id
+			_peg.external = append(_peg.external, id)
+		} else {
+			_peg.names[s] = pegquote(name)
+		}
+	}
+}
+
+// put a terminal in the quotes of the PEG syntax in use
+func pegquote(body string) string {
+	if _peg.pigeon {
+		return strconv.Quote(body)
+	}
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(body, `\`, `\\`), "'", `\'`) + "'"
+}
+
+// put out one PEG word, wrapping long lines
+func pegword(w string) {
+	outspacelen(strwidth(w), _peg.contcol, ' ')
+	outstr(w)
+}
+
+// put out the alternatives alts as an ordered choice
+func pegalts(alts []*gnode) {
+	for i, a := range alts {
+		if i > 0 {
+			pegword("/")
+		}
+		pegexpr(a)
+	}
+}
+
+// put out tree t as a parsing expression
+func pegexpr(t *gnode) {
+	switch t.kind {
+	case GSYMBOL:
+		if name := _peg.names[t.sym]; name != "" {
+			pegword(name)
+		}
+	case GSEQ:
+		if len(t.kids) == 0 {
+			pegword(pegquote(""))
+		}
+		for _, k := range t.kids {
+			pegexpr(k)
+		}
+	case GALT:
+		if len(t.kids) == 1 {
+			pegexpr(t.kids[0])
+			return
+		}
+		pegword("(")
+		pegalts(t.kids)
+		pegword(")")
+	case GOPT, GREP:
+		suffix := "?"
+		if t.kind == GREP {
+			suffix = "*"
+		}
+		alts := nonempty(t)
+		if len(alts) == 1 && len(alts[0].kids) == 1 && alts[0].kids[0].kind == GSYMBOL {
+			pegword(_peg.names[alts[0].kids[0].sym] + suffix)
+			return
+		}
+		pegword("(")
+		pegalts(alts)
+		pegword(")" + suffix)
+	}
+}
+
+// writepeg
+// write grammar structure as a parsing expression grammar,
+// in pigeon syntax if style is "pigeon", else in generic syntax
+func writepeg(style string) {
+	var order []PSYMBOL
+	var absorbed map[PSYMBOL]bool
+	var count map[PSYMBOL]int
+	var barcol int
+	var comment string
+
+	outsetup()
+	_peg.pigeon = style == "pigeon"
+	comment = "#"
+	if _peg.pigeon {
+		comment = "//"
+	}
+	pegnames()
+
+	order = reachorder()
+	count = uses()
+	absorbed = map[PSYMBOL]bool{}
+	_peg.trees = map[PSYMBOL]*gnode{}
+	for _, s := range order {
+		if !absorbed[s] {
+			_peg.trees[s] = rulestotree(s)
+			rebracket(_peg.trees[s], s, count, absorbed)
+		}
+	}
+	var kept []PSYMBOL
+	for _, s := range order {
+		if !absorbed[s] {
+			kept = append(kept, s)
+		}
+	}
+
+	// warn about every change to the language
+	peganalyze(kept)
+	for _, s := range kept {
+		pegcheck(s, _peg.trees[s])
+	}
+	pegleftrecursion(kept)
+
+	for i, s := range kept {
+		if i > 0 {
+			outline()
+		}
+		outstr(_peg.names[s])
+		outchar(' ')
+		barcol = getoutcol()
+		outstr("<-")
+		_peg.contcol = barcol + 3
+		for i, a := range _peg.trees[s].kids {
+			if i > 0 {
+				outline()
+				outspaces(barcol)
+				outchar('/')
+			}
+			pegexpr(a)
+		}
+		outline()
+	}
+
+	if len(_peg.external) != 0 {
+		outline()
+		outstr(comment)
+		outstr(" rules defined elsewhere:")
+		for _, t := range _peg.external {
+			if getoutcol()+1+len(t) > 80 {
+				outline()
+				outstr(comment)
+			}
+			outchar(' ')
+			outstr(t)
+		}
+		outline()
+	}
+}
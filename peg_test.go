@@ -0,0 +1,69 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// each place where a PEG may not match what the grammar does is reported
+// on the line of the rule involved
+func TestPEGChecks(t *testing.T) {
+	var got []string
+
+	SetStdout(io.Discard)
+	defer SetStdout(os.Stdout)
+	SetDiagnostics(func(d Diagnostic) {
+		got = append(got, d.String())
+	})
+	defer SetDiagnostics(nil)
+
+	for _, tc := range []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{"prefix",
+			"> s\ns ::= a\n  | a b\n",
+			[]string{">>PEG: ALTERNATIVE OF s IS NEVER TRIED, AN EARLIER ONE IS ITS PREFIX on line 3<<"}},
+		{"empty",
+			"> s\n/ ''\ns ::= ''\n  | a\n  | b\n",
+			[]string{
+				">>PEG: ALTERNATIVE OF s IS NEVER TRIED, AN EARLIER ONE MATCHES EMPTY on line 4<<",
+				">>PEG: ALTERNATIVE OF s IS NEVER TRIED, AN EARLIER ONE MATCHES EMPTY on line 5<<",
+			}},
+		{"greedy",
+			"> s\ns ::= { a } a\n",
+			[]string{">>PEG: GREEDY { } IN s MAY TAKE WHAT FOLLOWS IT on line 2<<"}},
+		{"direct",
+			"> s\ns ::= b\n  | s a\nt ::= t c\n",
+			[]string{
+				">>PEG: s -> s IS LEFT RECURSIVE on line 3<<",
+				">>PEG: t -> t IS LEFT RECURSIVE on line 4<<",
+			}},
+		{"cycle",
+			"> s\ns ::= t a\n  | c\nt ::= s b\n",
+			[]string{">>PEG: s -> t -> s IS LEFT RECURSIVE on line 2<<"}},
+		{"through empty",
+			"> s\n/ ''\ns ::= e s a\n  | b\ne ::= ''\n  | x\n",
+			[]string{
+				">>PEG: ALTERNATIVE OF e IS NEVER TRIED, AN EARLIER ONE MATCHES EMPTY on line 6<<",
+				">>PEG: s -> s IS LEFT RECURSIVE on line 3<<",
+			}},
+		{"none",
+			"> s\ns ::= a\n  | b\n",
+			nil},
+	} {
+		got = nil
+		SetStdinReader(strings.NewReader(tc.src))
+		ReadGrammar()
+		WritePEG("peg")
+		if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+			t.Errorf("%s: got\n%s\nwant\n%s", tc.name, strings.Join(got, "\n"), strings.Join(tc.want, "\n"))
+		}
+	}
+}
@@ -0,0 +1,378 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Railroad diagrams.
+// Each nonterminal is drawn as an SVG railroad (syntax) diagram.  The
+// rules of the symbol are read as a rule tree, with the groups that
+// gdeebnf invented symbols for put back as writegoebnf does, and then
+//   - alternatives, ( a | b ), branch off the line and join it again;
+//   - an option, [ a ], is drawn on the line with a bypass over it;
+//   - a repetition, { a }, has a bypass over it and a loop back under it.
+// Terminals are drawn in round ended boxes, nonterminals in square boxes
+// that link to the diagram of that symbol.  Each diagram is written to
+// a file of its own, or all of them are written to one HTML page.
+
+// WriteRailroadSVG writes one SVG file per nonterminal into directory dir.
+func WriteRailroadSVG(dir string) error {
+	return writerailroadsvg(dir)
+}
+
+// WriteRailroadHTML writes one HTML page with all diagrams to stdout.
+func WriteRailroadHTML(title string) {
+	writerailroadhtml(title)
+}
+
+// sizes, in pixels
+const (
+	RRARC   = 10 // radius of the curves
+	RRGAP   = 10 // space between the items of a sequence
+	RRVSEP  = 8  // space between branches
+	RRBOXH  = 22 // height of a box
+	RRCHARW = 8  // width of a character in a box
+	RRPAD   = 10 // space around a diagram
+	RREND   = 20 // length of the lines at the start and end
+)
+
+// kinds of diagram item
+const (
+	RRTERMINAL = iota
+	RRNONTERMINAL
+	RRSEQ
+	RRCHOICE
+	RROPTIONAL
+	RRREPEAT
+)
+
+type rritem struct {
+	kind int
+	text string    // the label of a box
+	href string    // where a nonterminal box links to
+	kids []*rritem // the items of a sequence or the branches of a choice
+	w    int       // width
+	up   int       // height above the line
+	down int       // height below the line
+}
+
+// global variables private to this package
+var _railroad struct {
+	html  bool               // linking within one page, else between files
+	files map[PSYMBOL]string // base file name for each nonterminal
+	trees map[PSYMBOL]*gnode // rule trees of the symbols drawn
+	order []PSYMBOL          // the symbols drawn, in reachable order
+}
+
+// decide which symbols to draw and the file name for each
+func railroadsetup() {
+	var count map[PSYMBOL]int
+	var absorbed map[PSYMBOL]bool
+	var used map[string]bool
+	var order []PSYMBOL
+	var id string
+
+	order = reachorder()
+	count = uses()
+	absorbed = map[PSYMBOL]bool{}
+	_railroad.trees = map[PSYMBOL]*gnode{}
+	for _, s := range order {
+		if !absorbed[s] {
+			_railroad.trees[s] = rulestotree(s)
+			rebracket(_railroad.trees[s], s, count, absorbed)
+		}
+	}
+
+	_railroad.order = nil
+	_railroad.files = map[PSYMBOL]string{}
+	used = map[string]bool{}
+	for _, s := range order {
+		if absorbed[s] {
+			continue
+		}
+		id = identifier(symname(s))
+		if id == "" {
+			id = "rule"
+		}
+		_railroad.files[s] = uniquename(id, used)
+		_railroad.order = append(_railroad.order, s)
+	}
+}
+
+// the label put in the box for symbol s
+func rrlabel(s PSYMBOL) string {
+	name := symname(s)
+	if isbracketed(name) || (TERMINAL(s) && isquoted(name) && len(name) > 2) {
+		return name[1 : len(name)-1]
+	}
+	return name
+}
+
+// layout
+
+// rrbox makes the item for a box labelled text
+func rrbox(kind int, text string, href string) *rritem {
+	return &rritem{kind: kind, text: text, href: href,
+		w: strwidth(text)*RRCHARW + 2*RRGAP, up: RRBOXH / 2, down: RRBOXH / 2}
+}
+
+// rrseq makes a sequence of items
+func rrseq(kids []*rritem) *rritem {
+	var r *rritem
+
+	r = &rritem{kind: RRSEQ, kids: kids}
+	for i, k := range kids {
+		if i > 0 {
+			r.w += RRGAP
+		}
+		r.w += k.w
+		r.up = max(r.up, k.up)
+		r.down = max(r.down, k.down)
+	}
+	return r
+}
+
+// rrchoice makes a choice between branches, the first on the line
+func rrchoice(kids []*rritem) *rritem {
+	var r *rritem
+
+	if len(kids) == 1 {
+		return kids[0]
+	}
+	r = &rritem{kind: RRCHOICE, kids: kids}
+	for i, k := range kids {
+		r.w = max(r.w, k.w)
+		if i == 0 {
+			r.up = k.up
+		} else {
+			r.down += rrdrop(kids[i-1], k)
+		}
+	}
+	r.down += kids[len(kids)-1].down
+	r.w += 4 * RRARC
+	return r
+}
+
+// how far the line of branch k is drawn below that of the branch prev
+func rrdrop(prev *rritem, k *rritem) int {
+	return max(prev.down+RRVSEP+k.up, 2*RRARC)
+}
+
+// rrloop makes an option (bypass over) or repetition (bypass over and
+// loop under) of an item
+func rrloop(kind int, k *rritem) *rritem {
+	var r *rritem
+
+	r = &rritem{kind: kind, kids: []*rritem{k}, w: k.w + 4*RRARC}
+	r.up = rrclear(k.up) + RRARC
+	r.down = k.down
+	if kind == RRREPEAT {
+		r.down = rrclear(k.down) + RRARC
+	}
+	return r
+}
+
+// how far from the line a bypass or loop goes to clear something
+// reaching h from it
+func rrclear(h int) int {
+	return max(h+RRVSEP, 2*RRARC)
+}
+
+// rrlayout makes the diagram item for rule tree t
+func rrlayout(t *gnode) *rritem {
+	var kids []*rritem
+
+	switch t.kind {
+	case GSYMBOL:
+		if t.sym == emptypt {
+			return rrseq(nil)
+		} else if TERMINAL(t.sym) {
+			return rrbox(RRTERMINAL, rrlabel(t.sym), "")
+		}
+		return rrbox(RRNONTERMINAL, rrlabel(t.sym), rrhref(t.sym))
+	case GSEQ:
+		for _, k := range t.kids {
+			kids = append(kids, rrlayout(k))
+		}
+		return rrseq(kids)
+	case GALT:
+		for _, k := range t.kids {
+			kids = append(kids, rrlayout(k))
+		}
+		return rrchoice(kids)
+	}
+	for _, k := range nonempty(t) {
+		kids = append(kids, rrlayout(k))
+	}
+	if len(kids) == 0 {
+		return rrseq(nil)
+	}
+	if t.kind == GOPT {
+		return rrloop(RROPTIONAL, rrchoice(kids))
+	}
+	return rrloop(RRREPEAT, rrchoice(kids))
+}
+
+// where a box for nonterminal s links to, "" if s has no diagram
+func rrhref(s PSYMBOL) string {
+	file, ok := _railroad.files[s]
+	if !ok {
+		return ""
+	} else if _railroad.html {
+		return "#" + file
+	}
+	return file + ".svg"
+}
+
+// drawing
+
+type rrdrawing struct {
+	sb strings.Builder
+}
+
+func (d *rrdrawing) printf(format string, args ...any) {
+	d.sb.WriteString(sprintf(format, args...))
+}
+
+// a path of straight lines and quarter circles
+func (d *rrdrawing) path(format string, args ...any) {
+	d.printf("<path d=\"%s\"/>\n", sprintf(format, args...))
+}
+
+// draw item r with its entry on the line at x, y
+func (d *rrdrawing) draw(r *rritem, x int, y int) {
+	switch r.kind {
+	case RRTERMINAL, RRNONTERMINAL:
+		class, rx := "terminal", RRBOXH/2
+		if r.kind == RRNONTERMINAL {
+			class, rx = "nonterminal", 0
+		}
+		if r.href != "" {
+			d.printf("<a xlink:href=\"%s\">\n", xmlescape(r.href))
+		}
+		d.printf("<g class=\"%s\">\n", class)
+		d.printf("<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" rx=\"%d\" ry=\"%d\"/>\n",
+			x, y-RRBOXH/2, r.w, RRBOXH, rx, rx)
+		d.printf("<text x=\"%d\" y=\"%d\">%s</text>\n", x+r.w/2, y+4, xmlescape(r.text))
+		d.printf("</g>\n")
+		if r.href != "" {
+			d.printf("</a>\n")
+		}
+	case RRSEQ:
+		for i, k := range r.kids {
+			if i > 0 {
+				d.path("M%d %dh%d", x, y, RRGAP)
+				x += RRGAP
+			}
+			d.draw(k, x, y)
+			x += k.w
+		}
+	case RRCHOICE:
+		inner := r.w - 4*RRARC
+		yy := y
+		for i, k := range r.kids {
+			if i == 0 {
+				d.path("M%d %dh%d", x, y, 2*RRARC)
+				d.draw(k, x+2*RRARC, y)
+				d.path("M%d %dh%d", x+2*RRARC+k.w, y, inner-k.w+2*RRARC)
+				continue
+			}
+			yy += rrdrop(r.kids[i-1], k)
+			v := yy - y - 2*RRARC
+			d.path("M%d %da%d %d 0 0 1 %d %dv%da%d %d 0 0 0 %d %d",
+				x, y, RRARC, RRARC, RRARC, RRARC, v, RRARC, RRARC, RRARC, RRARC)
+			d.draw(k, x+2*RRARC, yy)
+			d.path("M%d %dh%da%d %d 0 0 0 %d %dv%da%d %d 0 0 1 %d %d",
+				x+2*RRARC+k.w, yy, inner-k.w, RRARC, RRARC, RRARC, -RRARC, -v, RRARC, RRARC, RRARC, -RRARC)
+		}
+	case RROPTIONAL, RRREPEAT:
+		k := r.kids[0]
+		inner := r.w - 4*RRARC
+		d.path("M%d %dh%d", x, y, 2*RRARC)
+		d.draw(k, x+2*RRARC, y)
+		d.path("M%d %dh%d", x+2*RRARC+k.w, y, inner-k.w+2*RRARC)
+		v := rrclear(k.up) - 2*RRARC
+		d.path("M%d %da%d %d 0 0 0 %d %dv%da%d %d 0 0 1 %d %dh%da%d %d 0 0 1 %d %dv%da%d %d 0 0 0 %d %d",
+			x, y, RRARC, RRARC, RRARC, -RRARC, -v, RRARC, RRARC, RRARC, -RRARC,
+			inner, RRARC, RRARC, RRARC, RRARC, v, RRARC, RRARC, RRARC, RRARC)
+		if r.kind == RRREPEAT {
+			v = rrclear(k.down) - 2*RRARC
+			d.path("M%d %da%d %d 0 0 1 %d %dv%da%d %d 0 0 1 %d %dh%da%d %d 0 0 1 %d %dv%da%d %d 0 0 1 %d %d",
+				x+r.w-2*RRARC, y, RRARC, RRARC, RRARC, RRARC, v, RRARC, RRARC, -RRARC, RRARC,
+				-inner, RRARC, RRARC, -RRARC, -RRARC, -v, RRARC, RRARC, RRARC, -RRARC)
+		}
+	}
+}
+
+// the style of the diagrams, put in each one so it stands alone
+const rrstyle = `<style>
+path { fill: none; stroke: #333; stroke-width: 1.5; }
+rect { stroke: #333; stroke-width: 1.5; }
+.terminal rect { fill: #ffc; }
+.nonterminal rect { fill: #cdf; }
+text { font: 13px monospace; text-anchor: middle; fill: #000; }
+a .nonterminal:hover rect { fill: #9bf; }
+</style>
+`
+
+// rrsvg returns the SVG diagram for nonterminal s
+func rrsvg(s PSYMBOL) string {
+	var d rrdrawing
+	var r *rritem
+	var w, h, y int
+
+	r = rrlayout(_railroad.trees[s])
+	w = r.w + 2*RREND + 2*RRPAD
+	h = r.up + r.down + 2*RRPAD
+	y = RRPAD + r.up
+	d.printf("<svg xmlns=\"http://www.w3.org/2000/svg\" xmlns:xlink=\"http://www.w3.org/1999/xlink\" class=\"railroad\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n",
+		w, h, w, h)
+	d.printf("<title>%s</title>\n", xmlescape(symname(s)))
+	d.printf("%s", rrstyle)
+	// the line starts and ends with a bar across it
+	d.path("M%d %dv%dM%d %dh%d", RRPAD, y-RRARC/2, RRARC, RRPAD, y, RREND)
+	d.draw(r, RRPAD+RREND, y)
+	d.path("M%d %dh%dv%dv%d", RRPAD+RREND+r.w, y, RREND, -RRARC/2, RRARC)
+	d.printf("</svg>\n")
+	return d.sb.String()
+}
+
+// escape text for XML and HTML
+func xmlescape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\"", "&quot;", "'", "&#39;").Replace(s)
+}
+
+// writerailroadsvg
+// write a railroad diagram for each nonterminal into a file in dir
+func writerailroadsvg(dir string) error {
+	_railroad.html = false
+	railroadsetup()
+	for _, s := range _railroad.order {
+		name := filepath.Join(dir, _railroad.files[s]+".svg")
+		if err := os.WriteFile(name, []byte(rrsvg(s)), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writerailroadhtml
+// write one HTML page with a railroad diagram for each nonterminal
+func writerailroadhtml(title string) {
+	_railroad.html = true
+	railroadsetup()
+	fputs("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n", stdout)
+	fprintf(stdout, "<title>%s</title>\n", xmlescape(title))
+	fputs("</head>\n<body>\n", stdout)
+	fprintf(stdout, "<h1>%s</h1>\n", xmlescape(title))
+	for _, s := range _railroad.order {
+		fprintf(stdout, "<h2 id=\"%s\">%s</h2>\n", xmlescape(_railroad.files[s]), xmlescape(symname(s)))
+		fputs(rrsvg(s), stdout)
+	}
+	fputs("</body>\n</html>\n", stdout)
+}
@@ -3,47 +3,77 @@
 
 package gtools
 
-// /* reachable.c */
-//
-// /* written by Douglas Jones, June 2010,
-//    based on pieces of cruncher,
-//      written in Pascal by Douglas Jones, March 1990
-//      rewritten in C, Jan 2007
-// */
-//
-// /* mark reachable symbols in grammar as TOUCHED */
-//
-// #include <stdlib.h>
-//
-// #include "grammar.h"
-// #include "reachable.h"
-//
-// /*
-//  * The interface
-//  */
-//
-// void reachsetup() { /* setup for reachability analysis */
-// 	PSYMBOL s;
-// 	for (s = symlist; s != NULL; s = s->next) s->state = UNTOUCHED;
-// }
-//
-// void reachtouch( PSYMBOL s ) { /* recursively touch reachable symbols */
-//
-// 	/* handles used in list traversals */
-// 	PPRODUCTION p;
-// 	PELEMENT e;
-// 	PSYMBOL ss;
-//
-// 	s->state = TOUCHED;
-//
-// 	for (p = s->data; p != NULL; p = p->next) {
-// 		/* for all production rules p */
-//
-// 		for (e = p->data; e != NULL; e = e->next) {
-// 			/* for all elements e of rule p, touch the symbol */
-//
-// 			ss = e->data;
-// 			if (ss->state == UNTOUCHED) reachtouch( ss );
-// 		}
-// 	}
-// }
+// written by Douglas Jones, June 2010,
+// based on pieces of cruncher, written in Pascal by Douglas Jones, March 1990
+// rewritten in C, Jan 2007
+
+// mark reachable symbols in grammar as TOUCHED
+
+// The interface
+
+// void reachsetup()
+// setup for reachability analysis
+func reachsetup() {
+	var s PSYMBOL
+	for s = symlist; s != nil; s = s.next {
+		s.state = UNTOUCHED
+	}
+}
+
+// void reachtouch( PSYMBOL s )
+// recursively touch reachable symbols
+func reachtouch(s PSYMBOL) {
+	// handles used in list traversals
+	var p PPRODUCTION
+	var e PELEMENT
+	var ss PSYMBOL
+
+	s.state = TOUCHED
+
+	for p = s.data; p != nil; p = p.next {
+		// for all production rules p
+
+		for e = p.data; e != nil; e = e.next {
+			// for all elements e of rule p, touch the symbol
+
+			ss = e.data
+			if ss.state == UNTOUCHED {
+				reachtouch(ss)
+			}
+		}
+	}
+}
+
+// reachorder lists the nonterminals in the order that writeg puts them out,
+// depth first from the distinguished symbol, followed by the nonterminals
+// that can't be reached from it.  Reachable symbols are left TOUCHED.
+func reachorder() []PSYMBOL {
+	var s PSYMBOL
+	var order []PSYMBOL
+
+	var walk func(s PSYMBOL)
+	walk = func(s PSYMBOL) {
+		if NONTERMINAL(s) {
+			order = append(order, s)
+		}
+		s.state = TOUCHED
+		for p := s.data; p != nil; p = p.next {
+			for e := p.data; e != nil; e = e.next {
+				if e.data.state == UNTOUCHED {
+					walk(e.data)
+				}
+			}
+		}
+	}
+
+	reachsetup()
+	if head != nil {
+		walk(head)
+	}
+	for s = symlist; s != nil; s = s.next {
+		if NONTERMINAL(s) && s.state == UNTOUCHED {
+			order = append(order, s)
+		}
+	}
+	return order
+}
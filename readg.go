@@ -3,22 +3,44 @@
 
 package gtools
 
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
 func ReadGrammar() {
 	readg()
 }
 
 // global variables private to this package
 var (
-	ch   byte // static // most recent char read from stdin (could be EOF)
+	ch   rune // static // most recent char read from stdin (could be REOF)
 	line int  // static // current line number on stdin, used in error reports
+	col  int  // static // column of ch on its line, counting characters
 )
 
 // parsing utility
 var (
-	endlist bool // set by nonblank at end of list, reset when understood
-	endrule bool // set by nonblank at end of rule, reset when understood
+	endlist    bool    // set by nonblank at end of list, reset when understood
+	endrule    bool    // set by nonblank at end of rule, reset when understood
+	blanks     int     // count of blank lines skipped by the last nonblank
+	symspan    span    // where the symbol getsymbol got most recently was
+	ruleweight float64 // the weight getsymlist found for its rule, 1 if none
+	weightat   span    // where that weight was given, nowhere if none
+	toolong    bool    // the symbol getname is getting didn't fit
+	headat     span    // where the distinguished symbol was given
+	emptyat    span    // where the empty symbol was given
+
+	reported map[errorkey]bool // errors reported since newgrammar
 )
 
+// an error, as far as telling whether it was already reported goes
+type errorkey struct {
+	msg string
+	at  span
+}
+
 // PSYMBOL definesym( char * str )
 // define str in the main symbol list, it must not be already there
 func definesym(str []byte) PSYMBOL {
@@ -55,30 +77,110 @@ func definesym(str []byte) PSYMBOL {
 // output an error message msg attributed to the given source line
 // use -1 as a line number if line attribution does not work
 func errormsg(msg string, line int) {
-	fputs(" >>", stderr)
-	fputs(msg, stderr)
+	if line > 0 && repeated(msg, span{file: srcfile, line: line}) {
+		return
+	}
+	if diagnose != nil {
+		if line > 0 {
+			diagnose(diagnostic(msg, span{file: srcfile, line: line, endline: line}))
+		} else {
+			diagnose(diagnostic(msg, span{}))
+		}
+		return
+	}
+	errorline(msg, line, srcfile)
+}
+
+// errorline writes the error message msg for line of file on stderr
+func errorline(msg string, line int, file string) {
+	fputs(" "+errortext(msg, line, file)+"\n", stderr)
+}
+
+// errortext is the error message msg for line of file as it is written,
+// naming the file if there is one, so that an error in an included file
+// says which file it is in
+func errortext(msg string, line int, file string) string {
+	var text string
+
+	text = ">>" + msg
 	if line > 0 {
-		fprintf(stderr, " on line %d", line)
+		text = text + sprintf(" on line %d", line)
+		if file != "" {
+			text = text + " of " + file
+		}
+	} else if file != "" {
+		text = text + " in " + file
 	}
-	fputs("<<\n", stderr)
+	return text + "<<"
+}
+
+// errorat is errormsg for an error at sp
+func errorat(msg string, sp span) {
+	if sp.line > 0 && repeated(msg, sp) {
+		return
+	}
+	if diagnose != nil {
+		diagnose(diagnostic(msg, sp))
+		return
+	}
+	errorline(msg, sp.line, sp.file)
+}
+
+// errorin is errormsg for an error on line that is known to be at sp,
+// which goes to SetDiagnostics, and whose file goes into the message
+func errorin(msg string, line int, sp span) {
+	if sp.line == 0 {
+		errormsg(msg, line)
+	} else if repeated(msg, sp) {
+		return
+	} else if diagnose != nil {
+		diagnose(diagnostic(msg, sp))
+	} else {
+		errorline(msg, line, sp.file)
+	}
+}
+
+// repeated reports whether the error msg at sp was already reported since
+// the grammar was started, noting it if not, so that an error met again
+// as the grammar is processed is only reported once
+func repeated(msg string, sp span) bool {
+	var key errorkey
+
+	key = errorkey{msg, sp}
+	if reported[key] {
+		return true
+	}
+	if reported == nil {
+		reported = map[errorkey]bool{}
+	}
+	reported[key] = true
+	return false
 }
 
 // static void extendsym( int * len, char * str, char ch )
-func extendsym(len *int, str []byte, ch byte) {
-	if *len >= SYMLEN {
-		errormsg("SYMBOL TOO LONG", line)
+// add ch to str, in UTF-8, if there is room for all of it
+func extendsym(len *int, str []byte, ch rune) {
+	var buf [utf8.UTFMax]byte
+	var n int
+
+	n = utf8.EncodeRune(buf[:], ch)
+	if *len+n > SYMLEN {
+		toolong = true // reported by getname, once for the symbol
 	} else {
-		*len = *len + 1
-		str[*len] = ch
+		copy(str[*len+1:], buf[:n])
+		*len = *len + n
 	}
 }
 
 // static PPRODUCTION getprod()
 // get a list of production rules
 func getprod() PPRODUCTION {
-	var ph PPRODUCTION // the head of the production list
-	var p PPRODUCTION  // the current production
-	var np PPRODUCTION // the new production
+	var ph PPRODUCTION  // the head of the production list
+	var p PPRODUCTION   // the current production
+	var np PPRODUCTION  // the new production
+	var start span      // where the new production starts
+	var alt PPRODUCTION // the production the alternative of np starts with
+	var depth int       // the bracket nesting at the start of np
 
 	ph = nil
 	p = nil
@@ -87,14 +189,31 @@ func getprod() PPRODUCTION {
 	for firstTime := true; firstTime || !endrule; firstTime = false {
 		np = NEWPRODUCTION()
 		np.line = line
+		start = here()
 		nonblank()
 		if !endlist { // the normal case
+			ruleweight = 1
+			weightat = span{}
 			np.data = getsymlist()
+			np.pos = elemspan(np.data)
+			if depth == 0 {
+				alt = np
+			}
+			depth = nesting(np.data, depth)
+			if weightat.line != 0 && depth > 0 { // a bar inside brackets comes next
+				errorat("WEIGHT INSIDE BRACKETS", weightat)
+			} else if weightat.line != 0 {
+				// it weights the whole alternative, which is what
+				// gdeebnf leaves of alt once it takes out the groups
+				alt.weight = ruleweight
+			}
 		} else { // nothing there
-			errormsg("EMPTY PRODUCTION RULE", np.line)
+			np.pos = start
+			errorat("EMPTY PRODUCTION RULE", np.pos)
 			if emptypt != nil {
 				np.data = NEWELEMENT()
 				np.data.line = line
+				np.data.pos = np.pos
 				np.data.next = nil
 				np.data.data = emptypt
 			} else {
@@ -118,37 +237,45 @@ func getprod() PPRODUCTION {
 }
 
 // static PSYMBOL getsymbol()
-// get symbol from input to str
+// get symbol from input, defining it if need be
 func getsymbol() PSYMBOL {
+	return seen(lookupordefine(getname()), symspan)
+}
+
+// getname gets the name of a symbol from input to str, setting symspan
+func getname() []byte {
 	var str [SYMLEN + 1]byte // most recent symbol from stdin
 	// string length is encoded in str[0]
 
 	var len int // index of last used space in str
+	var start span
 
 	// Must be called with ch nonblank, first char of symbol
-	len = 1
-	str[len] = ch
+	start = here()
+	len = 0
+	toolong = false
+	extendsym(&len, str[:], ch)
 
 	if ch == '<' { // may be a < quoted symbol
-		ch = getchar()
-		if ((ch <= 'z') && (ch >= 'a')) || ((ch <= 'Z') && (ch >= 'A')) || ((ch <= '9') && (ch >= '0')) { // definitely < quoted
+		ch = nextch()
+		if unicode.IsLetter(ch) || unicode.IsDigit(ch) { // definitely < quoted
 			for { // consume bracketed symbol
 				extendsym(&len, str[:], ch)
 				if ch == '>' {
 					break
 				}
-				ch = getchar()
+				ch = nextch()
 				if ch == '\n' {
 					break
 				}
-				if ch == EOF {
+				if ch == REOF {
 					break
 				}
 			}
 			if ch == '>' { // normal end of symbol
-				ch = getchar() // skip trailing >
+				ch = nextch() // skip trailing >
 			} else { // abnormal end of symbol
-				errormsg("MISSING CLOSING > MARK", line)
+				errorat("MISSING CLOSING > MARK", here())
 
 				// fake it
 				extendsym(&len, str[:], '>')
@@ -156,7 +283,7 @@ func getsymbol() PSYMBOL {
 		} else { // symbol ends at next blank (broadly speaking)
 			for { // symbol
 				extendsym(&len, str[:], ch)
-				ch = getchar()
+				ch = nextch()
 				if ch == ' ' {
 					break
 				}
@@ -166,31 +293,31 @@ func getsymbol() PSYMBOL {
 				if ch == '\n' {
 					break
 				}
-				if ch == EOF {
+				if ch == REOF {
 					break
 				}
 			}
 		}
 	} else if (ch == '"') || (ch == '\'') { // quoted
-		ch = getchar()
-		for (ch != str[1]) && (ch != '\n') && (ch != EOF) {
+		ch = nextch()
+		for (ch != rune(str[1])) && (ch != '\n') && (ch != REOF) {
 			extendsym(&len, str[:], ch)
-			ch = getchar()
+			ch = nextch()
 		}
-		if ch == str[1] {
+		if ch == rune(str[1]) {
 			extendsym(&len, str[:], ch)
-			ch = getchar()
+			ch = nextch()
 		} else {
-			errormsg("MISSING CLOSING QUOTE", line)
+			errorat("MISSING CLOSING QUOTE", here())
 
 			// fake it
-			extendsym(&len, str[:], str[1])
+			extendsym(&len, str[:], rune(str[1]))
 		}
 	} else { // symbol did not begin with < or quote, ends with space
-		ch = getchar()
-		for (ch != ' ') && (ch != '\t') && (ch != '\n') && (ch != EOF) {
+		ch = nextch()
+		for (ch != ' ') && (ch != '\t') && (ch != '\n') && (ch != REOF) {
 			extendsym(&len, str[:], ch)
-			ch = getchar()
+			ch = nextch()
 		}
 	}
 
@@ -199,25 +326,43 @@ func getsymbol() PSYMBOL {
 		panic("assert(0 <= len <= 255")
 	}
 	str[0] = byte(len) // record symbol length
-	return lookupordefine(str[:])
+	symspan = spanto(start, here())
+	if toolong {
+		errorat("SYMBOL TOO LONG", symspan)
+	}
+	return str[:len+1]
 }
 
 // static PELEMENT getsymlist()
 // get the list of symbols on RHS of rule
 func getsymlist() PELEMENT {
 	var s PELEMENT
+	var str []byte
 
 	nonblank()
 	if endlist {
 		endlist = false
 		return nil
-	} else {
-		s = NEWELEMENT()
-		s.line = line
-		s.data = getsymbol()
-		s.next = getsymlist()
-		return s
 	}
+	str = getname()
+	if isweight(str) { // an annotation, not a symbol
+		if weightat.line != 0 {
+			errorat("EXTRA WEIGHT", symspan)
+			errorat("WEIGHT FIRST GIVEN", weightat)
+		} else {
+			ruleweight = getweight(str, symspan)
+			weightat = symspan
+		}
+		return getsymlist()
+	} else if weightat.line != 0 { // it goes at the end of the rule
+		errorat("WEIGHT NOT AT END OF RULE", weightat)
+	}
+	s = NEWELEMENT()
+	s.line = line
+	s.data = seen(lookupordefine(str), symspan)
+	s.pos = symspan
+	s.next = getsymlist()
+	return s
 }
 
 // static PSYMBOL lookupordefine( char * str )
@@ -234,6 +379,29 @@ func lookupordefine(str []byte) PSYMBOL {
 	return definesym(str)
 }
 
+// lookupname is lookupsym for a Go string
+func lookupname(name string) PSYMBOL {
+	return lookupsym(symstr(name))
+}
+
+// namedsym is lookupordefine for a Go string
+func namedsym(name string) PSYMBOL {
+	return lookupordefine(symstr(name))
+}
+
+// symstr converts a Go string to the length-prefixed form used by the
+// symbol table, truncating it if it is too long to be a symbol
+func symstr(name string) []byte {
+	if len(name) > SYMLEN {
+		errormsg("SYMBOL TOO LONG", line)
+		name = name[:SYMLEN]
+		for !utf8.ValidString(name) { // don't split a character
+			name = name[:len(name)-1]
+		}
+	}
+	return append([]byte{byte(len(name))}, name...)
+}
+
 // PSYMBOL lookupsym( char * str )
 // lookup str in the main symbol list, return NULL if not found
 // str[0] is length of symbol, in characters
@@ -262,55 +430,249 @@ func lookupsym(str []byte) PSYMBOL {
 	return nil
 }
 
+// nextch gets the next char from stdin, counting columns
+func nextch() rune {
+	col = col + 1
+	return getrune()
+}
+
+// here is an empty span at ch
+func here() span {
+	return span{file: srcfile, line: line, col: col, endline: line, endcol: col}
+}
+
 // static void newline()
 // advance to next line, called when ch == '\n'
 func newline() {
 	line = line + 1
-	ch = getchar()
+	col = 0
+	ch = nextch()
 }
 
 // static void nonblank()
 // fancy scan for a nonblank character in ch
 func nonblank() {
-	for ch == '|' || ch == ' ' || ch == '\t' || ch == '\n' || ch == EOF {
+	var linestart bool // nothing but blanks since the last newline
+
+	blanks = 0
+	for ch == '|' || ch == ' ' || ch == '\t' || ch == '\n' || ch == REOF {
 		if ch == '|' {
 			endlist = true
-			ch = getchar()
+			ch = nextch()
 			return
-		} else if ch == EOF {
+		} else if ch == REOF {
 			endrule = true
 			endlist = true
 			return
 		} else if ch == '\n' {
+			if linestart { // a line of nothing but blanks
+				blanks = blanks + 1
+			}
 			newline()
+			linestart = true
 			if ch != ' ' && ch != '\t' { // line starts with nonblank
 				endrule = true
 				endlist = true
 				return
 			}
 		} else { // must have been blank or tab
-			ch = getchar()
+			ch = nextch()
 		}
 	}
 	return
 }
 
+// static void endperiod( PPRODUCTION p )
+// remove the period that ends the rule whose first production is p
+func endperiod(p PPRODUCTION) {
+	var pe *PELEMENT
+
+	for p.next != nil {
+		p = p.next
+	}
+	if p.data == nil || p.data.next == nil {
+		return // a rule of just a period is left alone
+	}
+	pe = &(p.data)
+	for (*pe).next != nil {
+		pe = &((*pe).next)
+	}
+	if symname((*pe).data) == "." {
+		*pe = nil
+		p.pos = elemspan(p.data)
+	}
+}
+
+// static void dropperiod()
+// take the period out of the symbol list if endperiod took all its uses
+func dropperiod() {
+	var ps *PSYMBOL
+	var s PSYMBOL
+	var p PPRODUCTION
+	var e PELEMENT
+	var period PSYMBOL
+
+	period = lookupname(".")
+	if period == nil || period.data != nil || period == head || period == emptypt {
+		return
+	}
+	for s = symlist; s != nil; s = s.next {
+		for p = s.data; p != nil; p = p.next {
+			for e = p.data; e != nil; e = e.next {
+				if e.data == period {
+					return
+				}
+			}
+		}
+	}
+	for ps = &symlist; *ps != period; ps = &((*ps).next) {
+	}
+	*ps = period.next
+	if symlistend == &(period.next) {
+		symlistend = ps
+	}
+}
+
 // static void skipline()
 // skip the rest of this line
 func skipline() {
-	for ch != '\n' && ch != EOF {
-		ch = getchar()
+	for ch != '\n' && ch != REOF {
+		ch = nextch()
 	}
 	if ch == '\n' {
 		newline()
 	}
 }
 
+// skipstatement skips the rest of this line and the lines that continue
+// it, so that a line that isn't a rule doesn't make errors of them too
+func skipstatement() {
+	skipline()
+	for ch == ' ' || ch == '\t' {
+		skipwhite()
+		if ch == '\n' || ch == REOF { // a blank line ends it
+			return
+		}
+		skipline()
+	}
+}
+
+// fillempty makes the empty productions, which getprod couldn't fill in
+// because the empty symbol was given after them, refer to the empty symbol
+func fillempty() {
+	var s PSYMBOL
+	var p PPRODUCTION
+
+	for s = symlist; s != nil; s = s.next {
+		for p = s.data; p != nil; p = p.next {
+			if p.data == nil {
+				p.data = NEWELEMENT()
+				p.data.line = p.line
+				p.data.pos = p.pos
+				p.data.next = nil
+				p.data.data = emptypt
+			}
+		}
+	}
+}
+
 // static void skipwhite()
 // simple scan for a nonblank character in ch
 func skipwhite() {
 	for ch == '\t' || ch == ' ' {
-		ch = getchar()
+		ch = nextch()
+	}
+}
+
+// newgrammar
+// empty the global grammar structure so that a new grammar can be built
+func newgrammar() {
+	stringlim = 0 // no characters have been put in stringtab
+	symlist = nil // no symbols have been encountered
+	symlistend = &symlist
+	head = nil       // we have no distinguished symbol
+	emptypt = nil    // we have no empty symbol
+	description = "" // we have no description
+	preamble = ""    // and no comments
+	trailer = ""
+	headnote = ""
+	emptynote = ""
+	reported = nil // and no errors
+}
+
+// static char * getcomment()
+// get a comment line as it stands, called when ch == COMMENT
+func getcomment() string {
+	var text []byte
+
+	for ch != '\n' && ch != REOF {
+		text = utf8.AppendRune(text, ch)
+		ch = nextch()
+	}
+	if ch == '\n' {
+		newline()
+	}
+	return string(text)
+}
+
+// commenttext is the text of a comment line, without the COMMENT mark
+// and the space after it
+func commenttext(raw string) string {
+	raw = raw[1:]
+	if len(raw) > 0 && raw[0] == ' ' {
+		raw = raw[1:]
+	}
+	return raw
+}
+
+// static char * restofline()
+// get the rest of this line as it stands, up to the newline
+func restofline() string {
+	var text []byte
+
+	for ch != '\n' && ch != REOF {
+		text = utf8.AppendRune(text, ch)
+		ch = nextch()
+	}
+	return string(text)
+}
+
+// isgenerated reports whether a comment line is one that writeg makes up,
+// rather than one that came from a source file; these are not kept, or
+// copying a grammar would add another copy of them each time
+func isgenerated(raw string) bool {
+	for _, prefix := range [...]string{
+		" start set:", " follow set:", " example:", " terminals:",
+		" unused productions", " unused terminals:", " no distinguished symbol!",
+	} {
+		if strings.HasPrefix(raw[1:], prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// trimblank removes the blank lines from the end of the source lines in text
+func trimblank(text string) string {
+	for text == "\n" || strings.HasSuffix(text, "\n\n") {
+		text = text[:len(text)-1]
+	}
+	return text
+}
+
+// describe appends the comment lines to the description in *desc
+func describe(desc *string, comment []string) {
+	if len(comment) == 0 {
+		return
+	}
+	if *desc != "" {
+		*desc = *desc + "\n\n"
+	}
+	for i, text := range comment {
+		if i > 0 {
+			*desc = *desc + "\n"
+		}
+		*desc = *desc + text
 	}
 }
 
@@ -319,100 +681,191 @@ func readg() {
 	var s PSYMBOL
 	var p PPRODUCTION
 	var ok bool
+	var at span      // where the metarule being read is
+	var lhs span     // where the symbol of the rule being read is
+	var end *PSYMBOL // the end of the symbol list before the rule
+
+	// comments are kept as descriptions; a block of comment lines just
+	// before a rule describes its symbol, any other block describes the
+	// rule before it, or the grammar if there is none
+	var comment []string // comment lines not yet attached
+	var last PSYMBOL     // the symbol of the most recent rule
+
+	// comments and blank lines are also kept as they stand, with the rule
+	// that follows them, or the preamble or trailer of the grammar
+	var layout string  // source lines not yet attached
+	var generated bool // the last comment line was made up by writeg
+	var lastdesc = func() *string {
+		if last == nil {
+			return &description
+		}
+		return &(last.comment)
+	}
 
 	// global initialization
-	stringlim = 0 // no characters have been put in stringtab
-	symlist = nil // no symbols have been encountered
-	symlistend = &symlist
-	head = nil    // we have no distinguished symbol
-	emptypt = nil // we have no empty symbol
+	newgrammar()
+	includes = nil
+	headat = span{}
+	emptyat = span{}
 
 	// prime the input stream
 	line = 1
-	ch = getchar()
+	col = 0
+	ch = nextch()
 	endlist = false
 	endrule = false
 
-	// while (ch != EOF) {
-	for ch != EOF {
+	// while (ch != REOF) {, going back to the including file at its end
+	for ch != REOF || endinclude() {
 		// while (ch == '\n') newline();
 		for ch == '\n' {
+			describe(lastdesc(), comment) // a blank line ends the block
+			comment = nil
+			if !generated {
+				layout = layout + "\n"
+			}
 			newline()
 		}
+		if ch == '>' || ch == '/' || ch == '@' { // a metarule also ends the block
+			describe(lastdesc(), comment)
+			comment = nil
+			generated = false
+			if last == nil { // comments before the rules go first
+				preamble = preamble + layout
+				layout = ""
+			}
+		}
 		if ch == '>' { // Identify distinguished symbol
-			if head != nil {
-				errormsg("EXTRA DISTINGUISHED SYMBOL", line)
-			} else {
-				ch = getchar() /* skip > */
-				skipwhite()
-				if (ch == '\n') || (ch == EOF) {
-					errormsg("NO DISTINGUISHED SYMBOL", line)
+			at = here()
+			ch = nextch() /* skip > */
+			skipwhite()
+			if head == nil {
+				if (ch == '\n') || (ch == REOF) {
+					errorat("NO DISTINGUISHED SYMBOL", at)
 				} else {
 					head = getsymbol()
+					headat = symspan
+					headnote = restofline()
 				}
+			} else if ch == '\n' || ch == REOF || at.file == headat.file || lookupsym(getname()) != head {
+				// another file may declare the same one again
+				errorat("EXTRA DISTINGUISHED SYMBOL", at)
+				errorat("DISTINGUISHED SYMBOL FIRST GIVEN", headat)
 			}
 			skipline()
 		} else if ch == '/' { // Identify the empty (/)symbol
-			if emptypt != nil {
-				errormsg("EXTRA EMPTY SYMBOL", line)
-				skipline()
-			} else {
-				ch = getchar() /* skip */
-				skipwhite()
-				if ch == '\n' || ch == EOF {
-					errormsg("NO EMPTY SYMBOL", line)
+			at = here()
+			ch = nextch() /* skip */
+			skipwhite()
+			if emptypt == nil {
+				if ch == '\n' || ch == REOF {
+					errorat("NO EMPTY SYMBOL", at)
 				} else {
 					emptypt = getsymbol()
+					emptyat = symspan
+					emptynote = restofline()
 				}
+			} else if ch == '\n' || ch == REOF || at.file == emptyat.file || lookupsym(getname()) != emptypt {
+				errorat("EXTRA EMPTY SYMBOL", at)
+				errorat("EMPTY SYMBOL FIRST GIVEN", emptyat)
 			}
 			skipline()
+		} else if ch == '@' { // a directive, only @ include for now
+			getdirective()
 		} else if ch == COMMENT { // COMMENT
-			skipline()
-		} else if ch != EOF { // WE MIGHT HAVE A RULE
+			raw := getcomment()
+			if isgenerated(raw) {
+				generated = true
+				layout = trimblank(layout)
+			} else if !(generated && strings.HasPrefix(raw, string(COMMENT)+"  ")) {
+				// not a continuation of a made up comment either
+				generated = false
+				comment = append(comment, commenttext(raw))
+				layout = layout + raw + "\n"
+			}
+		} else if ch != REOF { // WE MIGHT HAVE A RULE
+			generated = false
+			end = symlistend
 			s = getsymbol()
+			lhs = symspan
 			skipwhite()
 
 			ok = false
 			if ch == ':' { // consume ::= or := or : or =
 				ok = true
-				ch = getchar()
+				ch = nextch()
 				if ch == ':' {
-					ch = getchar()
+					ch = nextch()
 					if ch == '=' {
-						ch = getchar()
+						ch = nextch()
 					}
 				} else if ch == '=' {
-					ch = getchar()
+					ch = nextch()
 				}
 			} else if ch == '=' {
 				ok = true
-				ch = getchar()
+				ch = nextch()
 			}
 
 			if ok { // WE HAVE A RULE s ::= rule
+				describe(&(s.comment), comment)
+				comment = nil
+				if last == nil && head != nil && strings.HasPrefix(layout, "\n") {
+					// writeg puts a blank line after the metarules
+					layout = layout[1:]
+				}
+				last = s
 				p = s.data
 				if p == nil {
 					s.data = getprod()
+					p = s.data
 				} else {
 					for p.next != nil {
 						p = p.next
 					}
 					p.next = getprod()
+					p = p.next
+				}
+				p.comment = layout
+				for np := p; np != nil; np = np.next {
+					np.lhs = lhs
+				}
+				layout = strings.Repeat("\n", blanks) // skipped after the rule
+				if _format.Period {
+					endperiod(p)
 				}
 			} else { // NOT A RULE, JUST s ...comment
-				errormsg("MISSING ::= OR EQUIVALENT", line)
-				skipline()
+				errorat("MISSING ::= OR EQUIVALENT", here())
+				if symlistend != end { // s is new, forget it
+					*end = nil
+					symlistend = end
+				}
+				skipstatement()
 			}
 		}
 	}
 
+	if _format.Period {
+		dropperiod()
+	}
+	if emptypt != nil {
+		fillempty()
+	}
+
+	describe(lastdesc(), comment)
+	if last == nil {
+		preamble = trimblank(preamble + layout)
+	} else {
+		trailer = trimblank(layout)
+	}
+
 	if head == nil {
 		errormsg("DISTINGUISHED SYMBOL NOT GIVEN", -1)
 	} else if TERMINAL(head) {
-		errormsg("DISTINGUISHED SYMBOL IS TERMINAL", head.line)
+		errorat("DISTINGUISHED SYMBOL IS TERMINAL", headat)
 	}
 	if (emptypt != nil) && (NONTERMINAL(emptypt)) {
-		errormsg("EMPTY SYMBOL IS NONTERMINAL", emptypt.data.line)
+		errorat("EMPTY SYMBOL IS NONTERMINAL", emptypt.data.pos)
 	}
 	line = -1 // mark any new line numbers as fictional
 }
@@ -0,0 +1,453 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Refactoring.
+// RenameSymbol gives a symbol a new name everywhere it is used.  Since
+// terminals and nonterminals share one namespace and <expr> and expr are
+// different symbols, the new name must not be in use, and must read back
+// as the same symbol.
+//
+// InlineSymbol replaces each use of a nonterminal with its rules and then
+// drops the nonterminal, a targeted version of what squeeze does for every
+// symbol with just one rule.  A use in a production with no EBNF
+// metasymbols is replaced by one copy of the production for each rule;
+// anywhere else, or if the rules are themselves EBNF, the use becomes the
+// group ( rule | rule ... ), so
+//    |
+//    |<a> ::= <b> <c>        with  <b> ::= x | y
+//    |
+// becomes <a> ::= x <c> | y <c>.
+//
+// ExtractRule takes elements from to to, counting from 1, of an
+// alternative of a nonterminal, also counting from 1, and puts them in the
+// rule of a new nonterminal, which takes their place.  Alternatives are
+// counted in the order they start, those inside brackets too, and a
+// bracketed group is one element, so in
+//    |
+//    |term ::= factor { ( '*' | '/' ) factor }
+//    |
+// alternative 2 is ( '*' | '/' ) factor, and extracting its element 1
+// as mulop gives mulop ::= '*' | '/', a ( ) group taken whole.
+
+func RenameSymbol(old string, new string) error {
+	return renamesym(old, new)
+}
+
+func InlineSymbol(name string) error {
+	return inlinesym(name)
+}
+
+func ExtractRule(name string, rule int, from int, to int, new string) error {
+	return extractrule(name, rule, from, to, new)
+}
+
+// findsym looks up a symbol for a refactoring, suggesting the symbol
+// that was perhaps meant if there is none of that name
+func findsym(name string) (PSYMBOL, error) {
+	var s PSYMBOL
+	var other string
+
+	s = lookupname(name)
+	if s != nil {
+		return s, nil
+	}
+	if isbracketed(name) || isquoted(name) {
+		other = name[1 : len(name)-1]
+	} else {
+		other = "<" + name + ">"
+	}
+	if lookupname(other) != nil {
+		return nil, fmt.Errorf("no symbol %s, did you mean %s?", name, other)
+	}
+	return nil, fmt.Errorf("no symbol %s", name)
+}
+
+// validname checks that readg would read name back as one symbol,
+// and that no symbol has that name already
+func validname(name string) error {
+	var r rune
+
+	if name == "" {
+		return fmt.Errorf("empty symbol name")
+	}
+	if len(name) > SYMLEN {
+		return fmt.Errorf("symbol name %s is longer than %d bytes", name, SYMLEN)
+	}
+	if !utf8.ValidString(name) || strings.ContainsAny(name, "\n\r") {
+		return fmt.Errorf("symbol name %q can't be read back", name)
+	}
+	r, _ = utf8.DecodeRuneInString(name[1:])
+	switch {
+	case name[0] == '"' || name[0] == '\'':
+		if len(name) < 2 || name[len(name)-1] != name[0] || strings.IndexByte(name[1:len(name)-1], name[0]) >= 0 {
+			return fmt.Errorf("quoted symbol name %s can't be read back", name)
+		}
+	case name[0] == '<' && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+		if name[len(name)-1] != '>' || strings.IndexByte(name[:len(name)-1], '>') >= 0 {
+			return fmt.Errorf("bracketed symbol name %s can't be read back", name)
+		}
+	default:
+		// at the start of a line, these would begin a metarule, a
+		// directive or an annotation, or be taken for the rule symbol
+		if strings.ContainsAny(name, " \t") || strings.ContainsRune("|/>@:=", rune(name[0])) || name[0] == COMMENT {
+			return fmt.Errorf("symbol name %s must be quoted", name)
+		}
+		// and outside -bnf, ( ) [ ] { } are EBNF brackets
+		if _, _, ok := metaname(name); ok {
+			return fmt.Errorf("symbol name %s would be read as an EBNF bracket, it must be quoted", name)
+		}
+	}
+	if lookupname(name) != nil {
+		return fmt.Errorf("there is already a symbol %s", name)
+	}
+	return nil
+}
+
+// storename puts name in the string table, returning where it is
+func storename(name string) STRINGPT {
+	var str []byte
+	var pos STRINGPT
+
+	str = symstr(name)
+	if int(stringlim)+len(str) > STRINGLIMIT {
+		errormsg("STRING POOL OVERVFLOW", -1)
+		return 0
+	}
+	pos = stringlim
+	copy(stringtab[pos:], str)
+	stringlim = stringlim + STRINGPT(len(str))
+	return pos
+}
+
+// unlinksym takes s out of the symbol list
+func unlinksym(s PSYMBOL) {
+	var ps *PSYMBOL
+
+	for ps = &symlist; *ps != nil && *ps != s; ps = &((*ps).next) {
+	}
+	if *ps == nil {
+		return
+	}
+	*ps = s.next
+	if symlistend == &(s.next) {
+		symlistend = ps
+	}
+}
+
+// renamesym
+// rename the symbol old to new
+func renamesym(old string, new string) error {
+	var s PSYMBOL
+	var err error
+
+	if s, err = findsym(old); err != nil {
+		return err
+	}
+	if err = validname(new); err != nil {
+		return err
+	}
+	s.name = storename(new)
+	return nil
+}
+
+// hasmeta reports whether any element of the list e is an EBNF metasymbol
+func hasmeta(e PELEMENT) bool {
+	for ; e != nil; e = e.next {
+		if _, _, ok := metakind(e.data); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// copyelements hangs a copy of the list of elements from e up to but not
+// including end at pe, returning where to hang what follows it
+func copyelements(pe *PELEMENT, e PELEMENT, end PELEMENT) *PELEMENT {
+	for ; e != end; e = e.next {
+		*pe = NEWELEMENT()
+		(*pe).line = e.line
+		(*pe).pos = e.pos
+		(*pe).data = e.data
+		pe = &((*pe).next)
+	}
+	*pe = nil
+	return pe
+}
+
+// inlinecopies replaces production p, which uses s at element e, with one
+// copy of p for each rule of s, the first of them p itself
+func inlinecopies(s PSYMBOL, p PPRODUCTION, e PELEMENT) {
+	var r PPRODUCTION
+	var np PPRODUCTION
+	var last PPRODUCTION
+	var next PPRODUCTION
+	var pe *PELEMENT
+	var data PELEMENT
+	var from []span
+
+	next = p.next
+	data = p.data
+	from = origins(p)
+	last = nil
+	for r = s.data; r != nil; r = r.next {
+		if last == nil {
+			np = p // the first copy reuses p
+		} else {
+			np = NEWPRODUCTION()
+			np.line = p.line
+			np.pos = p.pos
+			np.state = p.state
+			np.from = from
+			last.next = np
+		}
+		pe = copyelements(&(np.data), data, e)
+		pe = copyelements(pe, r.data, nil)
+		copyelements(pe, e.next, nil)
+		addorigins(np, r)
+		last = np
+	}
+	last.next = next
+}
+
+// inlinegroup replaces the use of s at element e of production p with the
+// group ( rule | rule ... ), splitting p into pieces as readg does for a
+// bar inside brackets, the first of them p itself
+func inlinegroup(s PSYMBOL, p PPRODUCTION, e PELEMENT) {
+	var r PPRODUCTION
+	var np PPRODUCTION
+	var next PPRODUCTION
+	var rest PELEMENT
+	var pe *PELEMENT
+	var pos span
+	var from []span
+
+	next = p.next
+	rest = e.next
+	pos = e.pos
+	from = origins(p)
+
+	// p ends with ( and the first rule
+	e.data = namedsym(metanames[GALT][0])
+	e.pos = spanstart(e.pos)
+	pe = copyelements(&(e.next), s.data.data, nil)
+	addorigins(p, s.data)
+	np = p
+
+	// each further rule is a piece of its own
+	for r = s.data.next; r != nil; r = r.next {
+		np.next = NEWPRODUCTION()
+		np = np.next
+		np.line = r.line
+		np.state = p.state
+		np.pos = r.pos
+		np.from = from
+		addorigins(np, r)
+		pe = copyelements(&(np.data), r.data, nil)
+	}
+
+	// and the last ends with ) and the rest of p
+	*pe = NEWELEMENT()
+	(*pe).line = e.line
+	(*pe).pos = spanend(pos)
+	(*pe).data = namedsym(metanames[GALT][1])
+	(*pe).next = rest
+	np.next = next
+}
+
+// inlinesym
+// replace each use of the nonterminal name with its rules
+func inlinesym(name string) error {
+	var s PSYMBOL
+	var ss PSYMBOL
+	var p PPRODUCTION
+	var r PPRODUCTION
+	var e PELEMENT
+	var err error
+	var group bool
+	var comment string
+
+	if s, err = findsym(name); err != nil {
+		return err
+	}
+	if TERMINAL(s) {
+		return fmt.Errorf("%s is a terminal, there is nothing to inline", name)
+	}
+	if s == head {
+		return fmt.Errorf("%s is the distinguished symbol", name)
+	}
+	for r = s.data; r != nil; r = r.next {
+		for e = r.data; e != nil; e = e.next {
+			if e.data == s {
+				return fmt.Errorf("%s is recursive, it can't be inlined", name)
+			}
+		}
+		if hasmeta(r.data) {
+			group = true
+		}
+		comment = comment + r.comment
+	}
+
+	for ss = symlist; ss != nil; ss = ss.next {
+		if ss == s {
+			continue
+		}
+		p = ss.data
+		for p != nil {
+			for e = p.data; e != nil && e.data != s; e = e.next {
+			}
+			if e == nil { // no more uses in p
+				p = p.next
+				continue
+			}
+			// s's comments go with the first rule it is inlined in
+			p.comment = comment + p.comment
+			comment = ""
+			// p is kept as the first of the rules that replace it,
+			// look at it again in case it uses s again
+			if s.data.next == nil || !(group || hasmeta(p.data)) {
+				inlinecopies(s, p, e)
+			} else {
+				inlinegroup(s, p, e)
+			}
+		}
+	}
+
+	s.data = nil
+	unlinksym(s)
+	return nil
+}
+
+// extractrule
+// put elements from to to of alternative rule of name under a new symbol;
+// the alternatives are those of rulestotree, numbered in the order they
+// start, so those inside brackets count, and a bracketed group is one
+// element
+func extractrule(name string, rule int, from int, to int, new string) error {
+	var s PSYMBOL
+	var ns PSYMBOL
+	var t *gnode
+	var alt *gnode
+	var nt *gnode
+	var top int // the top level alternative that holds alt
+	var pp *PPRODUCTION
+	var p PPRODUCTION
+	var rest PPRODUCTION
+	var scratch symbol   // to hang the new productions from
+	var orig PPRODUCTION // to gather the origins of the old ones
+	var err error
+
+	if s, err = findsym(name); err != nil {
+		return err
+	}
+	if TERMINAL(s) {
+		return fmt.Errorf("%s is a terminal, it has no rules", name)
+	}
+	t = rulestotree(s)
+	n := 0
+	for i, k := range t.kids {
+		if alt = nthalt(k, rule, &n); alt != nil {
+			top = i
+			break
+		}
+	}
+	if rule < 1 || alt == nil {
+		return fmt.Errorf("%s has no rule %d", name, rule)
+	}
+	if from < 1 || to < from {
+		return fmt.Errorf("bad range of elements %d to %d", from, to)
+	}
+	if to > len(alt.kids) {
+		return fmt.Errorf("rule %d of %s has fewer than %d elements", rule, name, to)
+	}
+	if err = validname(new); err != nil {
+		return err
+	}
+
+	// the new symbol and its rule, the elements as one alternative, or
+	// the alternatives of a ( ) group taken whole
+	kids := alt.kids[from-1 : to]
+	first, last := kids[0], kids[len(kids)-1]
+	ns = namedsym(new)
+	ns.line = first.line
+	ns.pos = spanto(first.pos, last.pos)
+	if len(kids) == 1 && first.kind == GALT {
+		nt = &gnode{kind: GALT, kids: first.kids, line: first.line}
+	} else {
+		nt = &gnode{kind: GSEQ, kids: append([]*gnode(nil), kids...), line: first.line}
+	}
+
+	// the new symbol takes the place of the elements
+	kids = append([]*gnode(nil), alt.kids[:from-1]...)
+	kids = append(kids, &gnode{kind: GSYMBOL, sym: ns, line: first.line, pos: ns.pos})
+	alt.kids = append(kids, alt.kids[to:]...)
+
+	// find the productions readg split the top level alternative into,
+	// the first at bracket depth 0 and the rest inside brackets
+	depth := 0
+	n = -1
+	for pp = &(s.data); ; pp = &((*pp).next) {
+		if depth == 0 {
+			n++
+		}
+		if n == top {
+			break
+		}
+		depth = nesting((*pp).data, depth)
+	}
+	p = *pp
+	orig = NEWPRODUCTION()
+	for rest, depth = p, 0; rest != nil; {
+		addorigins(orig, rest)
+		depth = nesting(rest.data, depth)
+		if rest = rest.next; depth == 0 {
+			break
+		}
+	}
+
+	// put the productions of the alternative as it now is in their
+	// place, keeping what was said of it
+	treetorules(&scratch, t.kids[top])
+	scratch.data.comment = p.comment
+	scratch.data.weight = p.weight
+	treetorules(ns, nt)
+	for _, q := range [...]PPRODUCTION{scratch.data, ns.data} {
+		for ; q != nil; q = q.next {
+			q.from = orig.from
+			q.state = p.state
+		}
+	}
+	*pp = scratch.data
+	for p = scratch.data; p.next != nil; p = p.next {
+	}
+	p.next = rest
+	return nil
+}
+
+// nthalt finds alternative rule of t, a top level alternative, counting
+// those in the order they start from *n, which it adds those in t to
+func nthalt(t *gnode, rule int, n *int) *gnode {
+	if t.kind == GSEQ {
+		*n = *n + 1
+		if *n == rule {
+			return t
+		}
+	}
+	for _, k := range t.kids {
+		if k.kind == GSYMBOL {
+			continue
+		}
+		if alt := nthalt(k, rule, n); alt != nil {
+			return alt
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"strings"
+	"testing"
+)
+
+// a symbol can't be renamed to an EBNF bracket, which would read back as
+// a bracket, unless ( ) [ ] { } are terminals
+func TestRenameBracket(t *testing.T) {
+	defer SetPlainBNF(false)
+	for _, tc := range []struct {
+		name string
+		bnf  bool
+		want string
+	}{
+		{"(", false, ""},
+		{"}", false, ""},
+		{"'('", false, "> s\n\ns ::= '(' b\n\n# terminals:   '(' b\n"},
+		{"(", true, "> s\n\ns ::= ( b\n\n# terminals:   ( b\n"},
+	} {
+		SetPlainBNF(tc.bnf)
+		got, errs := convert("> s\ns ::= a b\n", ReadGrammar, func() {
+			if err := RenameSymbol("a", tc.name); err != nil {
+				return
+			}
+			WriteGrammar()
+		})
+		if len(errs) != 0 {
+			t.Errorf("%s: unexpected errors %v", tc.name, errs)
+		}
+		if got != tc.want {
+			t.Errorf("%s with -bnf=%v: got\n%s\nwant\n%s", tc.name, tc.bnf, got, tc.want)
+		}
+	}
+}
+
+// alternatives are counted as rulestotree gives them, so one inside
+// brackets can be named, and a bracketed group is one element
+func TestExtractRule(t *testing.T) {
+	src := "> expression\n" +
+		"expression ::= term { ( '+' | '-' ) term }\n" +
+		"term ::= factor { ( '*' | '/' ) factor }\n" +
+		"factor ::= x\n"
+	for _, tc := range []struct {
+		rule, from, to int
+		want           string
+		err            bool
+	}{
+		{2, 1, 1, "term ::= factor { new factor }\nnew ::= '*'\n     |  '/'\n", false},
+		{3, 1, 1, "term ::= factor { ( new\n      |  '/' ) factor }\nnew ::= '*'\n", false},
+		{1, 2, 2, "term ::= factor new\nnew ::= { ( '*'\n     |  '/' ) factor }\n", false},
+		{2, 1, 2, "term ::= factor { new }\nnew ::= ( '*'\n     |  '/' ) factor\n", false},
+		{1, 2, 3, "", true},
+		{5, 1, 1, "", true},
+	} {
+		var err error
+
+		got, errs := convert(src, ReadGrammar, func() {
+			SetWriteOrder("source")
+			defer SetWriteOrder("reach")
+			if err = ExtractRule("term", tc.rule, tc.from, tc.to, "new"); err == nil {
+				WriteGrammar()
+			}
+		})
+		if len(errs) != 0 {
+			t.Errorf("%d %d %d: unexpected errors %v", tc.rule, tc.from, tc.to, errs)
+		}
+		if (err != nil) != tc.err {
+			t.Errorf("%d %d %d: got error %v", tc.rule, tc.from, tc.to, err)
+		} else if !strings.Contains(got, tc.want) {
+			t.Errorf("%d %d %d: got\n%s\nwant\n%s", tc.rule, tc.from, tc.to, got, tc.want)
+		}
+	}
+}
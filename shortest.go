@@ -0,0 +1,194 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+// Find the shortest string each nonterminal derives.
+// The fewest terminals a nonterminal derives is the least, over its
+// rules, of the sum of the fewest each symbol of the rule derives, a
+// terminal being one and the empty symbol none.  As with the markings of
+// deempty, this is found by going over the rules again and again until
+// nothing changes, starting with no nonterminal known to derive anything.
+// A rule is only taken for its symbol when it does better than the rule
+// taken before, so each symbol's rule is taken after the rules of the
+// symbols it uses, and expanding the rules taken always comes to an end.
+//    |
+//    |<a> ::= <b> 'x' | 'y' 'z'
+//    |<b> ::= <a> | <empty>
+//    |
+// gives
+//    |
+//    |<a> ::= <b> 'x'
+//    |     |  'y' 'z'
+//    |# example:    'x'
+//    |
+//    |<b> ::= <a>
+//    |     |  <empty>
+//    |# example:    <empty>
+//    |
+// A nonterminal that derives no string of terminals at all is reported.
+
+// global variables private to this package
+var _shortest struct {
+	length map[PSYMBOL]int         // the fewest terminals it derives, -1 for none
+	rule   map[PSYMBOL]PPRODUCTION // the rule taken to derive them
+}
+
+// Shortest finds the shortest string of terminals each nonterminal
+// derives, for writeg to put out as an example, reporting those that
+// derive none
+func Shortest() {
+	shortest()
+}
+
+// WriteDerivations writes the shortest string each nonterminal derives,
+// and the derivation of it as a tree of the rules used
+func WriteDerivations() {
+	writederivations()
+}
+
+// shortlength is the fewest terminals s derives, -1 if none are known yet
+func shortlength(s PSYMBOL) int {
+	if s == emptypt {
+		return 0
+	} else if TERMINAL(s) {
+		return 1
+	} else if n, ok := _shortest.length[s]; ok {
+		return n
+	}
+	return -1
+}
+
+// shortrule is the fewest terminals the symbols of p derive, -1 if some
+// symbol of it is not known to derive anything
+func shortrule(p PPRODUCTION) int {
+	var e PELEMENT
+	var n, m int
+
+	n = 0
+	for e = p.data; e != nil; e = e.next {
+		if m = shortlength(e.data); m < 0 {
+			return -1
+		}
+		n = n + m
+	}
+	return n
+}
+
+// shortexpand appends the terminals of the shortest string s derives
+func shortexpand(s PSYMBOL, str []PSYMBOL) []PSYMBOL {
+	var e PELEMENT
+
+	if s == emptypt {
+		return str
+	} else if TERMINAL(s) {
+		return append(str, s)
+	}
+	for e = _shortest.rule[s].data; e != nil; e = e.next {
+		str = shortexpand(e.data, str)
+	}
+	return str
+}
+
+// shortlengths finds the fewest terminals each nonterminal derives, and
+// the rule taken to derive them
+func shortlengths() {
+	var s PSYMBOL
+	var p PPRODUCTION
+	var n int
+	var change bool
+
+	_shortest.length = map[PSYMBOL]int{}
+	_shortest.rule = map[PSYMBOL]PPRODUCTION{}
+
+	// do {...} while (change)
+	for firstTime := true; firstTime || change; firstTime = false {
+		change = false
+		for s = symlist; s != nil; s = s.next {
+			if !NONTERMINAL(s) {
+				continue
+			}
+			for p = s.data; p != nil; p = p.next {
+				if n = shortrule(p); n >= 0 && (shortlength(s) < 0 || n < shortlength(s)) {
+					_shortest.length[s] = n
+					_shortest.rule[s] = p
+					change = true
+				}
+			}
+		}
+	}
+}
+
+// void shortest()
+// find the shortest string derived by each nonterminal
+func shortest() {
+	var s PSYMBOL
+
+	shortlengths()
+	for s = symlist; s != nil; s = s.next {
+		s.example = nil
+		if !NONTERMINAL(s) {
+			continue
+		} else if shortlength(s) < 0 {
+			errorin("DERIVES NO STRING: "+symname(s), s.data.line, s.data.lhs)
+			continue
+		}
+		s.example = shortexpand(s, []PSYMBOL{})
+	}
+}
+
+// examplestring is the example s derives as it would be written, the
+// empty symbol standing for the empty string
+func examplestring(s PSYMBOL) string {
+	var str string
+
+	if len(s.example) == 0 {
+		if emptypt != nil {
+			return symname(emptypt)
+		}
+		return ""
+	}
+	for _, t := range s.example {
+		if str != "" {
+			str = str + " "
+		}
+		str = str + symname(t)
+	}
+	return str
+}
+
+// writederivation writes the rule taken for s, indented for its depth in
+// the tree, and those taken for the nonterminals of it
+func writederivation(s PSYMBOL, indent string) {
+	var p PPRODUCTION
+	var e PELEMENT
+
+	p = _shortest.rule[s]
+	fputs(indent+symname(s)+" ::=", stdout)
+	if p.data == nil && emptypt != nil {
+		fputs(" "+symname(emptypt), stdout)
+	}
+	for e = p.data; e != nil; e = e.next {
+		fputs(" "+symname(e.data), stdout)
+	}
+	fputs("\n", stdout)
+	for e = p.data; e != nil; e = e.next {
+		if NONTERMINAL(e.data) {
+			writederivation(e.data, indent+"  ")
+		}
+	}
+}
+
+// void writederivations()
+// write the shortest string of each nonterminal and its derivation
+func writederivations() {
+	shortest()
+	for _, s := range reachorder() {
+		if s.example == nil {
+			continue
+		}
+		fputs(symname(s)+": "+examplestring(s)+"\n", stdout)
+		writederivation(s, "  ")
+		fputs("\n", stdout)
+	}
+}
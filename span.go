@@ -0,0 +1,133 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// Source positions.
+// readg records the span of every symbol, production and element it reads
+// in the file named by SetSourceName.  The transformations keep them: an
+// element copied from another keeps its span, a symbol invented for a
+// bracketed group gets the span of the group, and a production made from
+// others lists the spans of the source productions it came from, so that
+// what the tools say about a rule can point back at the source.
+
+// global variables private to this package
+var (
+	srcfile string // name of the file being read, "" for standard input
+)
+
+// SetSourceName sets the name of the file the input comes from, as it
+// is to appear in source positions; SetStdin sets it to the file opened
+func SetSourceName(name string) {
+	srcfile = name
+}
+
+// String gives sp as file:line:col-endline:endcol, or "" if it is nowhere
+func (sp span) String() string {
+	var text string
+
+	if sp.line == 0 {
+		return ""
+	}
+	if sp.file != "" {
+		text = sp.file + ":"
+	}
+	return text + sprintf("%d:%d-%d:%d", sp.line, sp.col, sp.endline, sp.endcol)
+}
+
+// spanto is the span from the start of a to the end of b, or just a
+// if b is nowhere
+func spanto(a, b span) span {
+	if b.line == 0 {
+		return a
+	}
+	a.endline = b.endline
+	a.endcol = b.endcol
+	return a
+}
+
+// spanstart is an empty span at the start of sp
+func spanstart(sp span) span {
+	sp.endline = sp.line
+	sp.endcol = sp.col
+	return sp
+}
+
+// spanend is an empty span at the end of sp
+func spanend(sp span) span {
+	sp.line = sp.endline
+	sp.col = sp.endcol
+	return sp
+}
+
+// elemspan is the span of the list of elements e, from the first to the
+// last that are somewhere
+func elemspan(e PELEMENT) span {
+	var first, last span
+
+	for ; e != nil; e = e.next {
+		if e.pos.line == 0 {
+			continue
+		}
+		if first.line == 0 {
+			first = e.pos
+		}
+		last = e.pos
+	}
+	return spanto(first, last)
+}
+
+// origins is where the source productions that p was made from are,
+// p itself if it was read as it stands
+func origins(p PPRODUCTION) []span {
+	if p.from == nil && p.pos.line != 0 {
+		return []span{p.pos}
+	}
+	return p.from
+}
+
+// addorigins notes that p was also made from the source productions of q
+func addorigins(p PPRODUCTION, q PPRODUCTION) {
+	var from []span
+
+	from = append(from, origins(p)...)
+next:
+	for _, sp := range origins(q) {
+		for _, have := range from {
+			if have == sp {
+				continue next
+			}
+		}
+		from = append(from, sp)
+	}
+	p.from = from
+}
+
+// bytespan is the span of src[start:end] for the readers of other
+// notations, which keep a byte offset and the line that start is on
+func bytespan(src []byte, line int, start, end int) span {
+	var sp span
+	var i int
+
+	sp.file = srcfile
+	sp.line = line
+	i = bytes.LastIndexByte(src[:start], '\n')
+	sp.col = utf8.RuneCount(src[i+1:start]) + 1
+	sp.endline = line + bytes.Count(src[start:end], []byte{'\n'})
+	i = bytes.LastIndexByte(src[:end], '\n')
+	sp.endcol = utf8.RuneCount(src[i+1:end]) + 1
+	return sp
+}
+
+// seen notes that s is at pos, if it has not been seen before
+func seen(s PSYMBOL, pos span) PSYMBOL {
+	if s.pos.line == 0 {
+		s.pos = pos
+	}
+	return s
+}
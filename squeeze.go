@@ -48,12 +48,22 @@ func squeezesymbols() {
 						/* first element of rule
 						   overwrites first element */
 						e.data = e1.data
+						e.line = e1.line
+						e.pos = e1.pos
+						addorigins(p, p1)
 						_squeeze.change = true
+						if s1 != head {
+							/* s1 goes, its comments go with its rule */
+							p.comment = p.comment + p1.comment
+							p1.comment = ""
+						}
 
 						/* now copy rest of rule */
 						e1 = e1.next
 						for e1 != nil {
 							e2 = NEWELEMENT()
+							e2.line = e1.line
+							e2.pos = e1.pos
 							e2.data = e1.data
 							e2.next = e.next
 							e.next = e2
@@ -90,6 +100,8 @@ func squeezerules() {
 				if samerule(p, q) {
 					/* rule q is redundant, eliminate it */
 					*qp = q.next
+					p.comment = p.comment + q.comment
+					addorigins(p, q)
 					_squeeze.change = true
 				} else {
 					/* move to next production */
@@ -8,23 +8,28 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"unicode/utf8"
 )
 
 var (
-	stdin  *os.File = os.Stdin
-	stdout *os.File = os.Stdout
-	stderr *os.File = os.Stderr
+	stdin  io.Reader = os.Stdin
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
 )
 
 const (
 	EOF byte = 255
 )
 
-func fprintf(fp *os.File, format string, args ...any) {
+func fprintf(fp io.Writer, format string, args ...any) {
 	_, _ = fmt.Fprintf(fp, format, args...)
 }
 
-func fputs(message string, fp *os.File) {
+func sprintf(format string, args ...any) string {
+	return fmt.Sprintf(format, args...)
+}
+
+func fputs(message string, fp io.Writer) {
 	_, _ = fmt.Fprint(fp, message)
 }
 
@@ -43,24 +48,89 @@ func getchar() byte {
 	return buffer[0]
 }
 
-func putchar(ch byte) {
-	_, _ = fmt.Fprintf(stdout, "%c", ch)
+// REOF is what getrune returns at the end of the input
+const REOF rune = -1
+
+// a byte getrune read too far, -1 if none
+var runeahead = -1
+
+// getrune reads one UTF-8 encoded character from stdin, returning
+// utf8.RuneError for a byte that doesn't start a valid encoding
+func getrune() rune {
+	var buf [utf8.UTFMax]byte
+	var n int
+	var c byte
+
+	if runeahead >= 0 {
+		c = byte(runeahead)
+		runeahead = -1
+	} else {
+		c = getchar()
+	}
+	if c == EOF {
+		// 0xff never appears in UTF-8, so this is really the end
+		return REOF
+	} else if c < utf8.RuneSelf {
+		return rune(c)
+	}
+	buf[0] = c
+	n = 1
+	for n < utf8.UTFMax && !utf8.FullRune(buf[:n]) {
+		c = getchar()
+		if c&0xc0 != 0x80 { // not a continuation byte, keep it for later
+			runeahead = int(c)
+			break
+		}
+		buf[n] = c
+		n++
+	}
+	r, _ := utf8.DecodeRune(buf[:n])
+	return r
 }
 
-func SetStdin(input string) error {
-	if stdin != nil {
-		_ = stdin.Close()
+// readall returns everything remaining on stdin
+func readall() []byte {
+	if stdin == nil {
+		return nil
 	}
+	buffer, err := io.ReadAll(stdin)
+	if err != nil {
+		panic(err)
+	}
+	return buffer
+}
+
+func putchar(ch byte) {
+	_, _ = stdout.Write([]byte{ch})
+}
 
+func SetStdin(input string) error {
 	fp, err := os.Open(input)
 	if err != nil {
 		return err
 	}
-	stdin = fp
+	SetStdinReader(fp)
+	srcfile = input
 
 	return nil
 }
 
+// SetStdinReader makes r the input of the package, closing the old input
+// if it can be closed; the input has no name until SetSourceName gives it one
+func SetStdinReader(r io.Reader) {
+	runeahead = -1
+	srcfile = ""
+	if fp, ok := stdin.(io.Closer); ok && stdin != nil {
+		_ = fp.Close()
+	}
+	stdin = r
+}
+
+// SetStdout makes w the output of the package
+func SetStdout(w io.Writer) {
+	stdout = w
+}
+
 func tocstring(s string) []byte {
 	return append([]byte(s), 0)
 }
@@ -0,0 +1,369 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Weights of the rules, for sample to pick them by.
+// Picking each rule of a nonterminal as often as the others makes most
+// strings either as short as they can be, or so deeply nested they never
+// end.  A rule can be given a weight, a number that says how often it is
+// picked against the other rules of its nonterminal, by an annotation at
+// the end of it, which is not a symbol of the rule
+//    |
+//    |<element> ::= <number> @weight=4
+//    |           |  <identifier> @weight=4
+//    |           |  ( <expression> )
+//    |
+// or by a file of weights, a line to a rule giving the nonterminal, the
+// rule of it counting from 1, and the weight
+//    |
+//    |# weights for bnf.gr
+//    |<element> 1 4
+//    |<element> 2 4
+//    |
+// A rule with no weight has weight 1, and one with weight 0 is never
+// picked if another rule can be.  A weight inside brackets is an error,
+// since the alternatives in them are not rules until gdeebnf makes them
+// so; one after a group with bars in it weights the whole rule.  Weights
+// can also be balanced, so that the strings are on average about as long
+// as asked.  Each rule's weight is multiplied by exp(-b * n), where n is
+// the fewest terminals the rule derives and b is found by bisection: the
+// larger b is, the more the rules that derive short strings are picked.  The average length of the
+// strings a nonterminal derives is the sum, over its rules, of the chance
+// of picking the rule times the average lengths of its symbols, found by
+// going over the rules until nothing changes, as shortest does.
+
+// the prefix of an annotation giving the weight of a rule
+const weightprefix = "@weight="
+
+// global variables private to this package
+var _weight struct {
+	base map[PPRODUCTION]float64 // the weights before balancing
+}
+
+// ReadWeights sets the weights of the rules from the named file
+func ReadWeights(name string) error {
+	return readweights(name)
+}
+
+// WriteWeights writes the weight of each rule, in the form ReadWeights
+// reads, with the average length of the strings sample writes
+func WriteWeights() {
+	writeweights()
+}
+
+// BalanceWeights changes the weights so that the strings sample writes
+// are length terminals long on average
+func BalanceWeights(length int) error {
+	return balanceweights(length)
+}
+
+// isweight reports whether the symbol read into str, its length first,
+// is an annotation giving the weight of a rule
+func isweight(str []byte) bool {
+	return strings.HasPrefix(string(str[1:]), weightprefix)
+}
+
+// getweight is the weight in the annotation read into str, reporting a
+// weight that isn't a number at sp
+func getweight(str []byte, sp span) float64 {
+	var w float64
+	var err error
+
+	w, err = strconv.ParseFloat(string(str[1+len(weightprefix):]), 64)
+	if err != nil || w < 0 || math.IsInf(w, 0) || math.IsNaN(w) {
+		errorat("BAD WEIGHT", sp)
+		return 1
+	}
+	return w
+}
+
+// weightname is the annotation giving a rule weight w
+func weightname(w float64) string {
+	return weightprefix + strconv.FormatFloat(w, 'g', -1, 64)
+}
+
+// weightpick picks a rule of s by the weights, or gives nil if they are
+// all 1, or all 0, and any rule will do
+func weightpick(s PSYMBOL) PPRODUCTION {
+	var p PPRODUCTION
+	var total, r float64
+	var weighted bool
+
+	for p = s.data; p != nil; p = p.next {
+		total = total + p.weight
+		if p.weight != 1 {
+			weighted = true
+		}
+	}
+	if !weighted || total <= 0 {
+		return nil
+	}
+	r = rand.Float64() * total
+	for p = s.data; p.next != nil; p = p.next {
+		if r < p.weight {
+			return p
+		}
+		r = r - p.weight
+	}
+	return p
+}
+
+// weightchance is the chance that p, a rule of s, is picked, as weightpick
+// and samplesym between them pick it
+func weightchance(s PSYMBOL, p PPRODUCTION) float64 {
+	var q PPRODUCTION
+	var total float64
+	var n int
+
+	for q = s.data; q != nil; q = q.next {
+		total = total + q.weight
+		n = n + 1
+	}
+	if total <= 0 {
+		return 1 / float64(n)
+	}
+	return p.weight / total
+}
+
+// weightlength is the average length of the strings sample writes for
+// the distinguished symbol, going over the nonterminals in order, or +Inf
+// if the derivations go on forever often enough that it has none
+func weightlength(order []PSYMBOL) float64 {
+	var length map[PSYMBOL]float64
+	var s PSYMBOL
+	var p PPRODUCTION
+	var e PELEMENT
+	var v, n, change float64
+
+	length = map[PSYMBOL]float64{}
+	for pass := 0; pass < 100000; pass++ {
+		change = 0
+		for _, s = range order {
+			v = 0
+			for p = s.data; p != nil; p = p.next {
+				n = 0
+				for e = p.data; e != nil; e = e.next {
+					if e.data == emptypt {
+						continue
+					} else if TERMINAL(e.data) {
+						n = n + 1
+					} else {
+						n = n + length[e.data]
+					}
+				}
+				v = v + weightchance(s, p)*n
+			}
+			change = math.Max(change, v-length[s])
+			length[s] = v
+		}
+		if length[head] > 1e9 {
+			break
+		} else if change <= 1e-9*math.Max(1, length[head]) {
+			return length[head]
+		}
+	}
+	return math.Inf(1)
+}
+
+// weighttilt sets the weights of the rules of the nonterminals in order to
+// their weights before balancing times exp(-b * n), n being the fewest
+// terminals the rule derives, or to 0 for a rule that derives nothing
+func weighttilt(order []PSYMBOL, b float64) {
+	var p PPRODUCTION
+	var n, most float64
+
+	for _, s := range order {
+		most = math.Inf(-1) // scale by the largest, so none overflows
+		for p = s.data; p != nil; p = p.next {
+			if shortrule(p) >= 0 {
+				most = math.Max(most, -b*float64(shortrule(p)))
+			}
+		}
+		for p = s.data; p != nil; p = p.next {
+			if n = float64(shortrule(p)); n < 0 {
+				p.weight = 0
+			} else {
+				p.weight = _weight.base[p] * math.Exp(-b*n-most)
+			}
+		}
+	}
+}
+
+// weightround scales the weights of the rules of each nonterminal in
+// order so the largest is 1, and rounds them to four digits, so that they
+// read back as they were written
+func weightround(order []PSYMBOL) {
+	var p PPRODUCTION
+	var most float64
+
+	for _, s := range order {
+		most = 0
+		for p = s.data; p != nil; p = p.next {
+			most = math.Max(most, p.weight)
+		}
+		for p = s.data; p != nil && most > 0; p = p.next {
+			p.weight, _ = strconv.ParseFloat(strconv.FormatFloat(p.weight/most, 'g', 4, 64), 64)
+		}
+	}
+}
+
+// weightreached lists the nonterminals that can be reached from the
+// distinguished symbol, in the order writeg puts them
+func weightreached() []PSYMBOL {
+	var reached []PSYMBOL
+
+	for _, s := range reachorder() {
+		if s.state == TOUCHED {
+			reached = append(reached, s)
+		}
+	}
+	return reached
+}
+
+// balanceweights
+// set the weights so that sample writes strings of length on average
+func balanceweights(length int) error {
+	var order []PSYMBOL
+	var lo, hi, b float64
+	var at func(b float64) float64
+	var unbalanced func(most string, best float64) error
+
+	if length <= 0 {
+		return fmt.Errorf("balance needs a length")
+	} else if head == nil || TERMINAL(head) {
+		return fmt.Errorf("no distinguished symbol")
+	}
+	order = weightreached()
+	shortlengths()
+	_weight.base = map[PPRODUCTION]float64{}
+	for _, s := range order {
+		for p := s.data; p != nil; p = p.next {
+			_weight.base[p] = p.weight
+		}
+	}
+	at = func(b float64) float64 {
+		weighttilt(order, b)
+		return weightlength(order)
+	}
+	unbalanced = func(most string, best float64) error {
+		for p, w := range _weight.base {
+			p.weight = w
+		}
+		return fmt.Errorf("the strings can't be %d tokens long on average, the %s they can be is %.4g",
+			length, most, best)
+	}
+
+	// find b too small, lo, and big enough, hi, doubling from 1
+	lo, hi = 0, 0
+	if at(0) > float64(length) {
+		for hi = 1; at(hi) > float64(length); hi = hi * 2 {
+			if hi >= 64 {
+				return unbalanced("shortest", at(hi))
+			}
+			lo = hi
+		}
+	} else {
+		for lo = -1; at(lo) <= float64(length); lo = lo * 2 {
+			if lo <= -64 {
+				return unbalanced("longest", at(lo))
+			}
+			hi = lo
+		}
+	}
+	for i := 0; i < 60; i++ {
+		b = (lo + hi) / 2
+		if at(b) > float64(length) {
+			lo = b
+		} else {
+			hi = b
+		}
+	}
+	weighttilt(order, hi)
+	weightround(order)
+	return nil
+}
+
+// readweights
+// set the weights of the rules from the file name
+func readweights(name string) error {
+	var data []byte
+	var s PSYMBOL
+	var p PPRODUCTION
+	var fields []string
+	var rule int
+	var w float64
+	var err error
+
+	if data, err = os.ReadFile(name); err != nil {
+		return err
+	}
+	for n, text := range strings.Split(string(data), "\n") {
+		text = strings.TrimSpace(text)
+		if text == "" || strings.HasPrefix(text, string(COMMENT)) {
+			continue
+		}
+		// the symbol may have blanks in it, the rule and weight can't
+		fields = strings.Fields(text)
+		if len(fields) < 3 {
+			return fmt.Errorf("%s:%d: want a symbol, a rule and a weight", name, n+1)
+		}
+		text = strings.TrimSpace(text[:strings.LastIndex(text, fields[len(fields)-1])])
+		text = strings.TrimSpace(text[:strings.LastIndex(text, fields[len(fields)-2])])
+		if s, err = findsym(text); err != nil {
+			return fmt.Errorf("%s:%d: %v", name, n+1, err)
+		} else if TERMINAL(s) {
+			return fmt.Errorf("%s:%d: %s is a terminal, it has no rules", name, n+1, text)
+		}
+		if rule, err = strconv.Atoi(fields[len(fields)-2]); err != nil {
+			return fmt.Errorf("%s:%d: %q is not a number", name, n+1, fields[len(fields)-2])
+		}
+		p = s.data
+		for i := 1; p != nil && i < rule; i++ {
+			p = p.next
+		}
+		if rule < 1 || p == nil {
+			return fmt.Errorf("%s:%d: %s has no rule %d", name, n+1, text, rule)
+		}
+		w, err = strconv.ParseFloat(fields[len(fields)-1], 64)
+		if err != nil || w < 0 || math.IsInf(w, 0) || math.IsNaN(w) {
+			return fmt.Errorf("%s:%d: %q is not a weight", name, n+1, fields[len(fields)-1])
+		}
+		p.weight = w
+	}
+	return nil
+}
+
+// writeweights
+// write the weight of each rule, and the average length of the strings
+func writeweights() {
+	var order []PSYMBOL
+	var length float64
+	var p PPRODUCTION
+	var rule int
+
+	order = weightreached()
+	if head != nil && NONTERMINAL(head) {
+		if length = weightlength(order); math.IsInf(length, 1) {
+			fputs("# the strings have no average length, some derivations never end\n", stdout)
+		} else {
+			fputs(sprintf("# the strings are %.4g tokens long on average\n", length), stdout)
+		}
+	}
+	for _, s := range reachorder() {
+		rule = 1
+		for p = s.data; p != nil; p = p.next {
+			fputs(sprintf("%s %d %s\n", symname(s), rule, strconv.FormatFloat(p.weight, 'g', -1, 64)), stdout)
+			rule = rule + 1
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"testing"
+)
+
+// a weight inside brackets is an error, and one after a group with bars
+// in it weights the whole rule, where gdeebnf leaves it
+func TestWeightBrackets(t *testing.T) {
+	src := "> b\n" +
+		"/ ''\n" +
+		"b ::= [ x @weight=2 | y ] | ( x | y ) z @weight=3\n"
+	want := "> b\n" +
+		"/ ''\n" +
+		"\n" +
+		"b ::= b-a\n" +
+		"   |  b-b z @weight=3\n" +
+		"b-a ::= ''\n" +
+		"     |  x\n" +
+		"     |  y\n" +
+		"b-b ::= x\n" +
+		"     |  y\n" +
+		"\n" +
+		"# terminals:   z '' x y\n"
+
+	got, errs := convert(src, ReadGrammar, func() {
+		GDeEBNF()
+		WriteGrammar()
+	})
+	if len(errs) != 1 || errs[0] != "WEIGHT INSIDE BRACKETS" {
+		t.Errorf("got errors %v, want WEIGHT INSIDE BRACKETS", errs)
+	}
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
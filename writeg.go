@@ -3,6 +3,13 @@
 
 package gtools
 
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
 // written by Douglas Jones, July 2010,
 // based on pieces of cruncher, written in Pascal by Douglas Jones, March 1990
 // rewritten in C, Jan 2007
@@ -30,27 +37,85 @@ package gtools
 //    |
 // If the start set and follow set of a nonterminal have been computed, these
 // are included in the output grammar as comments after all the rules for
-// that nonterminal.
+// that nonterminal, and so is the shortest string it derives if that has
+// been found.
 //    |
 //    |<nonterminal> ::= <a> 'b'
 //    |               |  <c> 'd'
 //    |\ start:  'a' 'c'
 //    |\ follow: 'g' 'h'
+//    |\ example: 'a' 'b'
 //    |
-// At the end, unreachable symbols and unused production rules are listed.
+// Comments kept from the source are put back: those that came before the
+// metarules first, those after the symbol of a metarule after it, those
+// that came before a rule ahead of all the rules for its nonterminal, and
+// those after the last rule after all of the reachable rules.
+// The rules are put out depth first from the distinguished symbol unless
+// SetWriteOrder asks for the order of the source or alphabetical order.
+// At the end, unreachable symbols and unused production rules are listed,
+// unless SetDropUnused asks for unused rules to be left out; in the order
+// of the source, unused rules stay where they were.  The terminals listed
+// are those of the rules put out.
 
 // global variables private to this package
 var (
 	barcol  int // static // column number for vertical bar
 	contcol int // static // column number for continuation
+
+	// the symbols of the rules, in the order they were put out, so the
+	// terminals are listed in an order that reading the copy keeps, and
+	// readback can put the symbol list in that order
+	written []PSYMBOL
+)
+
+// orders in which writeg can put out the rules
+const (
+	WRITEREACH  = iota // depth first from the distinguished symbol
+	WRITESOURCE        // the order of the rules in the source
+	WRITEALPHA         // alphabetical order of the nonterminals
+)
+
+// output options
+var (
+	writeorder int  = WRITEREACH // the order of the rules
+	dropunused bool = false      // leave out unreachable rules
 )
 
+// SetWriteOrder sets the order of the rules put out by WriteGrammar,
+// one of "reach", "source" or "alpha"
+func SetWriteOrder(order string) error {
+	switch order {
+	case "reach":
+		writeorder = WRITEREACH
+	case "source":
+		writeorder = WRITESOURCE
+	case "alpha":
+		writeorder = WRITEALPHA
+	default:
+		return fmt.Errorf("unknown rule order %q", order)
+	}
+	return nil
+}
+
+// SetDropUnused sets whether WriteGrammar leaves out unreachable rules
+// rather than listing them at the end
+func SetDropUnused(b bool) {
+	dropunused = b
+}
+
 // printing utility
 
 func WriteGrammar() {
 	writeg()
 }
 
+// ReadBack leaves the grammar as writing it and reading the copy back in
+// would, with the symbols listed in the order the copy has them and those
+// it doesn't have, such as the empty symbol deempty eliminates, dropped
+func ReadBack() {
+	readback()
+}
+
 // static void outprod( PPRODUCTION p )
 // put out RHS of rule p
 func outprod(p PPRODUCTION) {
@@ -62,36 +127,83 @@ func outprod(p PPRODUCTION) {
 			s = e.data
 			outspacesym(s, contcol, ' ')
 			outsymbol(s)
+			written = append(written, s)
 		}
 	}
 }
 
+// put out the annotation giving the weight w of a rule, if it has one
+func outweight(w float64) {
+	if w != 1 {
+		outspacelen(strwidth(weightname(w)), contcol, ' ')
+		outstr(weightname(w))
+	}
+}
+
 // static void outprodgroup( PSYMBOL s )
 // put out all rules with s on LHS
 func outprodgroup(s PSYMBOL) {
 	var p PPRODUCTION
 	var e PELEMENT
 	var ss PSYMBOL
+	var weight float64 // the weight of the alternative being put out
+	var depth int      // the bracket nesting at the end of p
 
 	outline()
+	for p = s.data; p != nil; p = p.next { // comments from the source
+		outcomment(p.comment)
+	}
 	outsymbol(s)
+	written = append(written, s)
 	outchar(' ')
-	barcol = getoutcol() + 1 // remember indent for next rule
-	outstr(RULESYM)
+	// remember indent for next rule, under the rule symbol where the
+	// alternatives after the bars line up with the first, so under the
+	// middle of ::= and the start of :=, and just before = and :
+	barcol = getoutcol() + strwidth(_format.RuleSym) - 2
+	outstr(_format.RuleSym)
 	contcol = getoutcol() + 1 // remember indent for continuation
+	if _format.Align == ALIGNINDENT {
+		barcol = _format.Indent
+		contcol = barcol + 3
+	}
 
-	// output first production on same line
+	// output first production on same line; the weight of an alternative
+	// split by bars inside brackets is that of its first production, and
+	// goes after the last
 	p = s.data
+	weight = p.weight
 	outprod(p)
+	if depth = nesting(p.data, 0); depth == 0 {
+		outweight(weight)
+	}
 
 	for p.next != nil { // output successive productions
 		p = p.next
+		if depth == 0 {
+			weight = p.weight
+		}
 
-		outline()
-		outspaces(barcol)
-		outstr("| ")
+		if _format.OneAlt {
+			outline()
+			outspaces(barcol)
+			outstr("| ")
+		} else { // on the same line if it fits
+			outspacelen(1, barcol, ' ')
+			if getoutcol() == barcol {
+				outstr("| ")
+			} else {
+				outchar('|')
+			}
+		}
 
 		outprod(p)
+		if depth = nesting(p.data, depth); depth == 0 {
+			outweight(weight)
+		}
+	}
+	if _format.Period {
+		outspacelen(1, contcol, ' ')
+		outchar('.')
 	}
 
 	if s.starter != nil { // output start set
@@ -118,12 +230,86 @@ func outprodgroup(s PSYMBOL) {
 			outsymbol(ss)
 		}
 	}
-	if s.follows != nil || s.starter != nil {
+	if s.example != nil { // output shortest string derived
+		outline()
+		outchar(COMMENT)
+		outstr(" example:")
+		if len(s.example) > 0 || emptypt != nil {
+			outstr("    ")
+		}
+		contcol = getoutcol() + 1 // remember indent
+
+		if len(s.example) == 0 && emptypt != nil {
+			outspacesym(emptypt, contcol, COMMENT)
+			outsymbol(emptypt)
+		}
+		for _, ss = range s.example {
+			outspacesym(ss, contcol, COMMENT)
+			outsymbol(ss)
+		}
+	}
+	if s.follows != nil || s.starter != nil || s.example != nil {
 		// output blank line to separate from the next rule
 		outline()
 	}
 }
 
+// static void outcomment( char * text )
+// put out source lines of comments and blanks, each ending in a newline
+// except perhaps the last
+func outcomment(text string) {
+	var i int
+
+	for text != "" {
+		i = strings.IndexByte(text, '\n')
+		if i < 0 {
+			outstr(text)
+			return
+		}
+		outstr(text[:i])
+		outline()
+		text = text[i+1:]
+	}
+}
+
+// ruleorder lists the nonterminals that have rules in the order given
+// by writeorder, other than WRITEREACH where they are in symlist order
+func ruleorder() []PSYMBOL {
+	var s PSYMBOL
+	var p PPRODUCTION
+	var list []PSYMBOL
+	var first map[PSYMBOL]int
+
+	first = map[PSYMBOL]int{}
+	for s = symlist; s != nil; s = s.next {
+		if s.data == nil {
+			continue
+		}
+		list = append(list, s)
+		// where the rules of s start, rules with no line go last
+		first[s] = int(^uint(0) >> 1)
+		for p = s.data; p != nil; p = p.next {
+			if p.line > 0 && p.line < first[s] {
+				first[s] = p.line
+			}
+		}
+	}
+	switch writeorder {
+	case WRITESOURCE:
+		sort.SliceStable(list, func(i, j int) bool {
+			if first[list[i]] != first[list[j]] {
+				return first[list[i]] < first[list[j]]
+			}
+			return list[i].line < list[j].line
+		})
+	case WRITEALPHA:
+		sort.SliceStable(list, func(i, j int) bool {
+			return symname(list[i]) < symname(list[j])
+		})
+	}
+	return list
+}
+
 // static void outreachable( PSYMBOL s )
 // recursively output reachable production
 func outreachable(s PSYMBOL) {
@@ -159,6 +345,55 @@ func outreachable(s PSYMBOL) {
 	}
 }
 
+// terminalorder lists the terminals in state, those in the rules put out
+// so far first, in the order they were put out, then the others in the
+// order of the symbol list, unless the unused rules are left out, and
+// with them the terminals that are in no rule put out
+func terminalorder(state STYPE) []PSYMBOL {
+	var listed map[PSYMBOL]bool
+	var order []PSYMBOL
+	var s PSYMBOL
+
+	listed = map[PSYMBOL]bool{}
+	for _, s = range written {
+		if s.data == nil && s.state == state && !listed[s] {
+			listed[s] = true
+			order = append(order, s)
+		}
+	}
+	for s = symlist; s != nil && !dropunused; s = s.next {
+		if s.data == nil && s.state == state && !listed[s] {
+			order = append(order, s)
+		}
+	}
+	return order
+}
+
+// readback
+// relink the symbol list in the order a copy of the grammar would have it
+func readback() {
+	var listed map[PSYMBOL]bool
+	var out io.Writer
+	var s PSYMBOL
+
+	out = stdout
+	stdout = io.Discard
+	writeg()
+	stdout = out
+
+	listed = map[PSYMBOL]bool{}
+	symlist = nil
+	symlistend = &symlist
+	for _, s = range append([]PSYMBOL{head, emptypt}, written...) {
+		if s != nil && !listed[s] {
+			listed[s] = true
+			s.next = nil
+			*symlistend = s
+			symlistend = &(s.next)
+		}
+	}
+}
+
 // void writeg()
 // write grammar structure documented in grammar.h
 func writeg() {
@@ -166,10 +401,13 @@ func writeg() {
 	var header bool
 
 	outsetup()
+	outcomment(preamble)
+	written = nil
 
 	if head != nil { // there is a distinguished symbol
 		outstr("> ")
 		outsymbol(head)
+		outstr(headnote)
 	} else {
 		outchar(COMMENT)
 		outstring(tocstring(" no distinguished symbol!"))
@@ -179,6 +417,7 @@ func writeg() {
 		outline()
 		outstr("/ ")
 		outsymbol(emptypt)
+		outstr(emptynote)
 	}
 
 	for s = symlist; s != nil; s = s.next {
@@ -186,28 +425,42 @@ func writeg() {
 	}
 	if head != nil {
 		outline()
-		outreachable(head)
+		if writeorder == WRITEREACH {
+			outreachable(head)
+		} else {
+			reachtouch(head)
+			for _, s = range ruleorder() {
+				if s.state == TOUCHED || (writeorder == WRITESOURCE && !dropunused) {
+					outprodgroup(s)
+				}
+			}
+		}
+	}
+	if trailer != "" { // comments from the end of the source
+		outline()
+		outcomment(strings.TrimSuffix(trailer, "\n")) // the last newline is put out below
 	}
 
 	header = false
-	for s = symlist; s != nil; s = s.next {
-		if (s.data == nil) && (s.state == TOUCHED) {
-			if !header {
-				outline()
-				outline()
-				outchar(COMMENT)
-				outstr(" terminals:  ")
-				contcol = getoutcol() + 1 // remember indent
-				header = true
-			}
-			outspacesym(s, contcol, COMMENT)
-			outsymbol(s)
+	for _, s = range terminalorder(TOUCHED) {
+		if !header {
+			outline()
+			outline()
+			outchar(COMMENT)
+			outstr(" terminals:  ")
+			contcol = getoutcol() + 1 // remember indent
+			header = true
 		}
+		outspacesym(s, contcol, COMMENT)
+		outsymbol(s)
 	}
 
 	header = false
-	for s = symlist; s != nil; s = s.next {
-		if (s.data != nil) && (s.state == UNTOUCHED) {
+	for _, s = range ruleorder() {
+		if writeorder == WRITESOURCE && head != nil {
+			break // they were put out in place
+		}
+		if !dropunused && (s.state == UNTOUCHED) {
 			if !header {
 				outline()
 				outline()
@@ -220,19 +473,17 @@ func writeg() {
 	}
 
 	header = false
-	for s = symlist; s != nil; s = s.next {
-		if (s.data == nil) && (s.state == UNTOUCHED) {
-			if !header {
-				outline()
-				outline()
-				outchar(COMMENT)
-				outstr(" unused terminals: ")
-				contcol = getoutcol() + 1 // remember indent
-				header = true
-			}
-			outspacesym(s, contcol, COMMENT)
-			outsymbol(s)
+	for _, s = range terminalorder(UNTOUCHED) {
+		if !header {
+			outline()
+			outline()
+			outchar(COMMENT)
+			outstr(" unused terminals: ")
+			contcol = getoutcol() + 1 // remember indent
+			header = true
 		}
+		outspacesym(s, contcol, COMMENT)
+		outsymbol(s)
 	}
 	outline()
 }
@@ -0,0 +1,71 @@
+// gtools - a collection of grammar manipulation tools
+// Copyright (c) 2023 Michael D Henderson. All rights reserved.
+
+package gtools
+
+import (
+	"strings"
+	"testing"
+)
+
+// in the order of the source an unused rule stays where it is, and
+// leaving out the unused rules leaves out the terminals only they use
+func TestWriteOrder(t *testing.T) {
+	src := "> a\n" +
+		"a ::= b x\n" +
+		"c ::= y z\n" +
+		"b ::= w\n"
+	for _, tc := range []struct {
+		order string
+		drop  bool
+		want  string
+	}{
+		{"source", false,
+			"> a\n\na ::= b x\nc ::= y z\nb ::= w\n\n# terminals:   x w\n\n# unused terminals:  y z\n"},
+		{"source", true,
+			"> a\n\na ::= b x\nb ::= w\n\n# terminals:   x w\n"},
+		{"reach", false,
+			"> a\n\na ::= b x\nb ::= w\n\n# terminals:   x w\n\n# unused productions\nc ::= y z\n\n# unused terminals:  y z\n"},
+		{"reach", true,
+			"> a\n\na ::= b x\nb ::= w\n\n# terminals:   x w\n"},
+	} {
+		if err := SetWriteOrder(tc.order); err != nil {
+			t.Fatal(err)
+		}
+		SetDropUnused(tc.drop)
+		got, _ := convert(src, ReadGrammar, WriteGrammar)
+		if got != tc.want {
+			t.Errorf("-order %s, dropunused %v: got\n%s\nwant\n%s", tc.order, tc.drop, got, tc.want)
+		}
+	}
+	_ = SetWriteOrder("reach")
+	SetDropUnused(false)
+}
+
+// with every rule symbol the alternatives after the bars, and the
+// continuation of a wrapped one, line up with the first alternative
+func TestWriteRuleSym(t *testing.T) {
+	src := "> s\ns ::= a | b c d e\n"
+	for _, tc := range []struct {
+		rulesym string
+		width   int // so that e doesn't fit after the bar
+		want    string
+	}{
+		{"::=", 12, "s ::= a\n   |  b c d\n      e\n"},
+		{":=", 11, "s := a\n  |  b c d\n     e\n"},
+		{"=", 10, "s = a\n |  b c d\n    e\n"},
+		{":", 10, "s : a\n |  b c d\n    e\n"},
+	} {
+		f := DefaultFormat()
+		f.RuleSym = tc.rulesym
+		f.Width = tc.width
+		if err := SetFormat(f); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := convert(src, ReadGrammar, WriteGrammar)
+		if !strings.HasPrefix(got, "> s\n\n"+tc.want+"\n") {
+			t.Errorf("-rulesym %s: got\n%s\nwant\n%s", tc.rulesym, got, tc.want)
+		}
+	}
+	_ = SetFormat(DefaultFormat())
+}
@@ -3,11 +3,59 @@
 
 package gtools
 
+import (
+	"fmt"
+	"unicode"
+)
+
 // global variables private to this package
 var (
 	column int // static // last column number filled on line
 )
 
+// Format configures the way writeg lays out a grammar.
+type Format struct {
+	Width   int    // lines are wrapped before this column, 0 for no wrapping
+	RuleSym string // between a nonterminal and its rules, ::= = : or :=
+	Align   int    // where the bar before an alternative goes
+	Indent  int    // column of the bar, when Align is ALIGNINDENT
+	OneAlt  bool   // put each alternative on a line of its own
+	Period  bool   // end each rule with a period, as Wirth did
+}
+
+// ways of aligning the bars before alternatives
+const (
+	ALIGNRULE   = iota // under the rule symbol
+	ALIGNINDENT        // at a fixed column
+)
+
+// the format in use
+var _format = DefaultFormat()
+
+// DefaultFormat returns the format that writeg has always used.
+func DefaultFormat() Format {
+	return Format{Width: 80, RuleSym: RULESYM, Align: ALIGNRULE, Indent: 4, OneAlt: true}
+}
+
+// SetFormat sets the format used by writeg.  The rule symbol must be one
+// that readg accepts, and with Period set readg takes a period at the
+// end of a rule to be the end of the rule rather than a terminal.
+func SetFormat(f Format) error {
+	switch f.RuleSym {
+	case "::=", "=", ":", ":=":
+	default:
+		return fmt.Errorf("unknown rule symbol %q", f.RuleSym)
+	}
+	if f.Align != ALIGNRULE && f.Align != ALIGNINDENT {
+		return fmt.Errorf("unknown alignment %d", f.Align)
+	}
+	if f.Indent < 0 || f.Width < 0 {
+		return fmt.Errorf("negative width or indent")
+	}
+	_format = f
+	return nil
+}
+
 // int getoutcol()
 // note what column we are on
 func getoutcol() int {
@@ -15,10 +63,12 @@ func getoutcol() int {
 }
 
 // void outchar( char ch )
-// put out one character
+// put out one byte, counting columns by character not by byte
 func outchar(ch byte) {
 	putchar(ch)
-	column++
+	if ch&0xc0 != 0x80 { // not a UTF-8 continuation byte
+		column++
+	}
 }
 
 // void outline()
@@ -45,14 +95,13 @@ func outspaces(c int) {
 // void outspacesym( PSYMBOL s, int c, char ch )
 // put out a space, or if s won't fit, return to column c starting the line with ch
 func outspacesym(s PSYMBOL, c int, ch byte) {
-	var len int      // length of s, in chars
-	var pos STRINGPT // position of s in stringtab
-
-	pos = s.name
-	len = int(stringtab[pos]) // works because length is encoded as first byte
+	outspacelen(strwidth(symname(s)), c, ch)
+}
 
-	// does s fit on the line?
-	if (column + 1 + len) > 80 { // no, move to next line
+// outspacelen is outspacesym for something len columns wide that isn't a symbol
+func outspacelen(len int, c int, ch byte) {
+	// does it fit on the line?
+	if _format.Width > 0 && (column+1+len) > _format.Width { // no, move to next line
 		outline()
 		if c > 1 {
 			outchar(ch)
@@ -65,12 +114,44 @@ func outspacesym(s PSYMBOL, c int, ch byte) {
 
 // copy of outstring for Go strings
 func outstr(s string) {
+	for i := 0; i < len(s); i++ {
+		putchar(s[i])
+	}
+	column += strwidth(s)
+}
+
+// runewidth is the number of columns r takes on a terminal: none for
+// combining marks and other characters of no width, two for the wide
+// characters of East Asian scripts, and one for everything else
+func runewidth(r rune) int {
+	switch {
+	case r == 0 || r == '\u200b' || r == '\u200c' || r == '\u200d' || r == '\ufeff':
+		return 0
+	case unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r):
+		return 0
+	case r >= 0x1100 && r <= 0x115f, // Hangul Jamo
+		r >= 0x2e80 && r <= 0xa4cf && r != 0x303f, // CJK ... Yi
+		r >= 0xac00 && r <= 0xd7a3,                // Hangul syllables
+		r >= 0xf900 && r <= 0xfaff,                // CJK compatibility ideographs
+		r >= 0xfe30 && r <= 0xfe4f,                // CJK compatibility forms
+		r >= 0xff00 && r <= 0xff60,                // fullwidth forms
+		r >= 0xffe0 && r <= 0xffe6,                // fullwidth signs
+		r >= 0x1f300 && r <= 0x1f64f,              // pictographs and emoticons
+		r >= 0x1f900 && r <= 0x1f9ff,              // supplemental pictographs
+		r >= 0x20000 && r <= 0x3fffd:              // CJK extensions
+		return 2
+	}
+	return 1
+}
+
+// strwidth is the number of columns s takes on a terminal
+func strwidth(s string) int {
+	var w int
+
 	for _, r := range s {
-		if !(0 <= r && r <= 255) {
-			panic("assert(0 <= r <= 255")
-		}
-		outchar(byte(r))
+		w += runewidth(r)
 	}
+	return w
 }
 
 // void outstring( char * p )
@@ -84,18 +165,14 @@ func outstring(p []byte) {
 	}
 }
 
+// symname returns the name of symbol s as a Go string
+func symname(s PSYMBOL) string {
+	var pos STRINGPT = s.name
+	return string(stringtab[pos+1 : pos+1+STRINGPT(stringtab[pos])])
+}
+
 // void outsymbol( PSYMBOL s )
 // put symbol to output
 func outsymbol(s PSYMBOL) {
-	var len int      // length of s, in chars
-	var pos STRINGPT // positiion of s in stringtab
-
-	pos = s.name
-	len = int(stringtab[pos]) // works because length is encoded as first byte
-
-	for len != 0 {
-		len = len - 1
-		pos = pos + 1
-		outchar(stringtab[pos])
-	}
+	outstr(symname(s))
 }